@@ -0,0 +1,116 @@
+package base
+
+import (
+	"fmt"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/golang/geo/r3"
+)
+
+// axis names used for both the viam r3 vectors and the ROS twist fields
+const (
+	axisLinearX  = "linear_x"
+	axisLinearY  = "linear_y"
+	axisLinearZ  = "linear_z"
+	axisAngularX = "angular_x"
+	axisAngularY = "angular_y"
+	axisAngularZ = "angular_z"
+)
+
+var axisNames = []string{axisLinearX, axisLinearY, axisLinearZ, axisAngularX, axisAngularY, axisAngularZ}
+
+// AxisMap maps one viam axis to a twist field
+type AxisMap struct {
+	Twist string  `json:"twist"`
+	Sign  float64 `json:"sign"`  // 1 or -1, defaults to 1
+	Scale float64 `json:"scale"` // defaults to 1
+}
+
+// axisMapping is keyed by viam axis name
+type axisMapping map[string]AxisMap
+
+// defaultAxisMapping drives a differential robot: viam forward (y) is ROS forward (x)
+var defaultAxisMapping = axisMapping{
+	axisLinearY:  {Twist: axisLinearX},
+	axisAngularZ: {Twist: axisAngularZ},
+}
+
+func validAxis(name string) bool {
+	for _, n := range axisNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m axisMapping) validate() error {
+	for from, to := range m {
+		if !validAxis(from) {
+			return fmt.Errorf("unknown axis %q in axis_mapping", from)
+		}
+		if !validAxis(to.Twist) {
+			return fmt.Errorf("unknown twist field %q for axis %q in axis_mapping", to.Twist, from)
+		}
+		if to.Sign != 0 && to.Sign != 1 && to.Sign != -1 {
+			return fmt.Errorf("sign for axis %q in axis_mapping must be 1 or -1", from)
+		}
+	}
+	return nil
+}
+
+// toTwist maps viam linear and angular vectors, already in m/s and rad/s, to a twist
+func (m axisMapping) toTwist(linear, angular r3.Vector) *geometry_msgs.Twist {
+	twist := &geometry_msgs.Twist{}
+	for from, to := range m {
+		sign, scale := to.Sign, to.Scale
+		if sign == 0 {
+			sign = 1
+		}
+		if scale == 0 {
+			scale = 1
+		}
+		if field := twistField(twist, to.Twist); field != nil {
+			*field += sign * scale * axisValue(linear, angular, from)
+		}
+	}
+	return twist
+}
+
+func axisValue(linear, angular r3.Vector, name string) float64 {
+	switch name {
+	case axisLinearX:
+		return linear.X
+	case axisLinearY:
+		return linear.Y
+	case axisLinearZ:
+		return linear.Z
+	case axisAngularX:
+		return angular.X
+	case axisAngularY:
+		return angular.Y
+	case axisAngularZ:
+		return angular.Z
+	default:
+		return 0
+	}
+}
+
+func twistField(twist *geometry_msgs.Twist, name string) *float64 {
+	switch name {
+	case axisLinearX:
+		return &twist.Linear.X
+	case axisLinearY:
+		return &twist.Linear.Y
+	case axisLinearZ:
+		return &twist.Linear.Z
+	case axisAngularX:
+		return &twist.Angular.X
+	case axisAngularY:
+		return &twist.Angular.Y
+	case axisAngularZ:
+		return &twist.Angular.Z
+	default:
+		return nil
+	}
+}
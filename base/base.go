@@ -3,14 +3,19 @@ package base
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/bluenviron/goroslib/v2"
 	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/nav_msgs"
 	"github.com/brokenrobotz/viam-ros-module/viamrosnode"
 	"github.com/golang/geo/r3"
 	viambase "go.viam.com/rdk/components/base"
+	"go.viam.com/rdk/components/movementsensor"
 	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/operation"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/spatialmath"
+	"math"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,18 +27,43 @@ var RosBaseModel = resource.NewModel("brokenrobotz", "ros", "base")
 type RosBase struct {
 	resource.Named
 
-	mu         sync.Mutex
-	nodeName   string
-	primaryUri string
-	topic      string
-	timeRate   time.Duration // ms to publish
-	node       *goroslib.Node
-	publisher  *goroslib.Publisher
-	twistMsg   *geometry_msgs.Twist
-	logger     logging.Logger
-	msgRate    *goroslib.NodeRate
-	closed     int32
-	moving     bool
+	mu               sync.Mutex
+	nodeName         string
+	primaryUri       string
+	topic            string
+	odomTopic        string
+	imuName          string
+	timeRate         time.Duration // ms to publish
+	publishMode      string
+	twistChanged     bool // set when a command changes twistMsg
+	commandTimeout   time.Duration
+	lastCommand      time.Time // last SetPower/SetVelocity, zero when not watched
+	stopRepeats      int       // zero twists left to send in until_stopped mode
+	node             *goroslib.Node
+	publisher        *goroslib.Publisher
+	odomSubscriber   *goroslib.Subscriber
+	twistMsg         *geometry_msgs.Twist // commanded twist
+	outputTwist      *geometry_msgs.Twist // published twist, ramped towards twistMsg
+	limits           velocityLimits
+	axes             axisMapping
+	messageType      string
+	frameId          string
+	seq              uint32
+	wheelbase        float64 // m, ackermann only
+	maxSteeringAngle float64 // rad, ackermann only
+	properties       viambase.Properties
+	geometries       []spatialmath.Geometry
+	odomMsg          *nav_msgs.Odometry
+	odomReceived     time.Time
+	movingFromOdom   bool    // IsMoving from odometry instead of commands
+	movingLinear     float64 // m/s threshold for movingFromOdom
+	movingAngular    float64 // rad/s threshold for movingFromOdom
+	imu              movementsensor.MovementSensor
+	logger           logging.Logger
+	msgRate          *goroslib.NodeRate
+	opMgr            *operation.SingleOperationManager
+	closed           int32
+	moving           bool
 }
 
 func init() {
@@ -55,19 +85,23 @@ func NewTrackedBase(
 	r := &RosBase{
 		Named:  conf.ResourceName().AsNamed(),
 		logger: logger,
+		opMgr:  operation.NewSingleOperationManager(),
 	}
 
 	if err := r.Reconfigure(ctx, deps, conf); err != nil {
 		return nil, err
 	}
 
-	// set twist message thread - need to stop sending after stop
+	// set twist message thread, publish_mode decides which ticks are written
 	go func() {
 		for atomic.LoadInt32(&r.closed) == 0 {
 			select {
 			case <-r.msgRate.SleepChan():
 				r.mu.Lock()
-				r.publisher.Write(r.twistMsg)
+				now := time.Now()
+				if twist, ok := r.publishTick(now); ok {
+					r.publisher.Write(r.toOutputMessage(twist, now))
+				}
 				r.mu.Unlock()
 			}
 		}
@@ -79,7 +113,7 @@ func NewTrackedBase(
 // Reconfigure clean this up
 func (r *RosBase) Reconfigure(
 	_ context.Context,
-	_ resource.Dependencies,
+	deps resource.Dependencies,
 	conf resource.Config,
 ) error {
 	var err error
@@ -88,14 +122,53 @@ func (r *RosBase) Reconfigure(
 	r.nodeName = conf.Attributes.String("node_name")
 	r.primaryUri = conf.Attributes.String("primary_uri")
 	r.topic = conf.Attributes.String("topic")
+	r.odomTopic = conf.Attributes.String("odom_topic")
+	r.imuName = conf.Attributes.String("imu")
+
+	r.publishMode = conf.Attributes.String("publish_mode")
+	if r.publishMode == "" {
+		r.publishMode = PublishContinuous
+	}
 
 	timeMs := conf.Attributes.Int("time_rate_ms", 250)
 
 	r.timeRate = time.Duration(timeMs) * time.Millisecond
-	r.twistMsg = &geometry_msgs.Twist{
+	r.commandTimeout = time.Duration(conf.Attributes.Int("command_timeout_ms", 0)) * time.Millisecond
+	cfg, err := resource.NativeConfig[*RosBaseConfig](conf)
+	if err != nil {
+		return err
+	}
+	r.messageType = cfg.MessageType
+	if r.messageType == "" {
+		r.messageType = MessageTwist
+	}
+	r.frameId = cfg.FrameId
+	if r.frameId == "" {
+		r.frameId = "base_link"
+	}
+	r.movingFromOdom = cfg.MovingFromOdometry
+	r.movingLinear = conf.Attributes.Float64("moving_linear_threshold_mps", 0.01)
+	r.movingAngular = conf.Attributes.Float64("moving_angular_threshold_dps", 1.0) * math.Pi / 180.0
+
+	r.wheelbase = cfg.Wheelbase
+	r.maxSteeringAngle = cfg.MaxSteeringAngle * math.Pi / 180.0
+
+	r.axes = defaultAxisMapping
+	if len(cfg.AxisMapping) > 0 {
+		r.axes = cfg.AxisMapping
+	}
+
+	r.limits = velocityLimits{
+		maxLinear:       conf.Attributes.Float64("max_linear_mps", 0),
+		maxAngular:      conf.Attributes.Float64("max_angular_dps", 0) * math.Pi / 180.0,
+		maxLinearAccel:  conf.Attributes.Float64("max_linear_accel_mps2", 0),
+		maxAngularAccel: conf.Attributes.Float64("max_angular_accel_dps2", 0) * math.Pi / 180.0,
+	}
+	r.setTwist(&geometry_msgs.Twist{
 		Linear:  geometry_msgs.Vector3{X: 0.0, Y: 0.0, Z: 0.0},
 		Angular: geometry_msgs.Vector3{X: 0.0, Y: 0.0, Z: 0.0},
-	}
+	}, false)
+	r.outputTwist = r.twistMsg
 
 	if len(strings.TrimSpace(r.primaryUri)) == 0 {
 		return errors.New("ROS primary uri must be set to hostname:port")
@@ -105,6 +178,15 @@ func (r *RosBase) Reconfigure(
 		return errors.New("ROS topic must be set to valid imu topic")
 	}
 
+	// optional movement sensor used for closed-loop spins
+	r.imu = nil
+	if len(strings.TrimSpace(r.imuName)) > 0 {
+		r.imu, err = movementsensor.FromDependencies(deps, r.imuName)
+		if err != nil {
+			return err
+		}
+	}
+
 	if r.publisher != nil {
 		r.publisher.Close()
 	}
@@ -114,94 +196,240 @@ func (r *RosBase) Reconfigure(
 		return err
 	}
 
+	r.properties, r.geometries, err = r.loadFootprint(cfg)
+	if err != nil {
+		return err
+	}
+
 	r.msgRate = r.node.TimeRate(r.timeRate)
 	if err != nil {
 		return err
 	}
 
-	// publisher for twist messages
+	// publisher for twist (or message_type) messages
 	r.publisher, err = goroslib.NewPublisher(goroslib.PublisherConf{
 		Node:  r.node,
 		Topic: r.topic,
-		Msg:   &geometry_msgs.Twist{},
+		Msg:   newOutputMessage(r.messageType),
 	})
 	if err != nil {
 		return err
 	}
+
+	// optional odometry used for closed-loop moves
+	if r.odomSubscriber != nil {
+		r.odomSubscriber.Close()
+		r.odomSubscriber = nil
+	}
+	r.odomMsg = nil
+	if len(strings.TrimSpace(r.odomTopic)) > 0 {
+		r.odomSubscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+			Node:     r.node,
+			Topic:    r.odomTopic,
+			Callback: r.processOdometry,
+		})
+		if err != nil {
+			return err
+		}
+	}
 	atomic.StoreInt32(&r.closed, 0)
 	return nil
 }
 
+// MoveStraight drives at mmPerSec until odometry reports distanceMm traveled
 func (r *RosBase) MoveStraight(
 	ctx context.Context,
 	distanceMm int,
 	mmPerSec float64,
-	extra map[string]interface{},
+	_ map[string]interface{},
 ) error {
-	r.logger.Warn("MoveStraight Not Implemented")
-	return nil
+	ctx, done := r.opMgr.New(ctx)
+	defer done()
+
+	if distanceMm == 0 || mmPerSec == 0 {
+		return r.Stop(ctx, nil)
+	}
+
+	start, err := r.waitForOdometry(ctx)
+	if err != nil {
+		return err
+	}
+
+	// a negative distance or speed (but not both) drives backwards
+	target := math.Abs(float64(distanceMm)) / 1000.0
+	speed := math.Abs(mmPerSec) / 1000.0
+	if (distanceMm < 0) != (mmPerSec < 0) {
+		speed = -speed
+	}
+
+	r.mu.Lock()
+	r.setTwist(r.axes.toTwist(r3.Vector{Y: speed}, r3.Vector{}), true)
+	// the limits may have clamped the speed
+	l := r.twistMsg.Linear
+	speed = math.Sqrt(l.X*l.X + l.Y*l.Y + l.Z*l.Z)
+	accel := r.limits.maxLinearAccel
+	r.mu.Unlock()
+	defer r.Stop(ctx, nil)
+	if speed == 0 {
+		return errors.New("MoveStraight requires linear_y to be mapped to a twist field")
+	}
+
+	timeout := time.NewTimer(moveTimeout(target, speed, accel))
+	defer timeout.Stop()
+	ticker := time.NewTicker(odomPollRate)
+	defer ticker.Stop()
+
+	traveled := 0.0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("MoveStraight timed out after %.3fm of %.3fm", traveled, target)
+		case <-ticker.C:
+			traveled = distanceTraveled(start, r.latestOdometry())
+			if moveDone(traveled, target, r.linearStoppingDistance()) {
+				return nil
+			}
+		}
+	}
 }
 
+// Spin turns at degsPerSec until the imu or odometry reports angleDeg of accumulated yaw
 func (r *RosBase) Spin(
 	ctx context.Context,
 	angleDeg,
 	degsPerSec float64,
-	extra map[string]interface{},
+	_ map[string]interface{},
 ) error {
-	r.logger.Warn("Spin Not Implemented")
-	return nil
+	ctx, done := r.opMgr.New(ctx)
+	defer done()
+
+	if angleDeg == 0 || degsPerSec == 0 {
+		return r.Stop(ctx, nil)
+	}
+
+	r.mu.Lock()
+	messageType := r.messageType
+	r.mu.Unlock()
+	if messageType == MessageAckermann {
+		return errors.New("Spin is not supported by ackermann bases")
+	}
+
+	prevYaw, err := r.currentYaw(ctx)
+	if err != nil {
+		return err
+	}
+
+	// positive angles turn counter-clockwise, a negative angle or speed (but not both) turns clockwise
+	target := math.Abs(angleDeg) * math.Pi / 180.0
+	speed := math.Abs(degsPerSec) * math.Pi / 180.0
+	if (angleDeg < 0) != (degsPerSec < 0) {
+		speed = -speed
+	}
+
+	r.mu.Lock()
+	r.setTwist(r.axes.toTwist(r3.Vector{}, r3.Vector{Z: speed}), true)
+	// the limits may have clamped the speed
+	twist := r.twistMsg
+	accel := r.limits.maxAngularAccel
+	r.mu.Unlock()
+	defer r.Stop(ctx, nil)
+
+	// measured yaw is in the ROS frame, so follow the sign of the mapped twist
+	if twist.Angular.Z == 0 {
+		return errors.New("Spin requires angular_z to be mapped to the twist angular_z")
+	}
+	direction := math.Copysign(1, twist.Angular.Z)
+	speed = math.Abs(twist.Angular.Z)
+
+	timeout := time.NewTimer(moveTimeout(target, speed, accel))
+	defer timeout.Stop()
+	ticker := time.NewTicker(odomPollRate)
+	defer ticker.Stop()
+
+	turned := 0.0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("Spin timed out after %.1f of %.1f degrees", turned*180.0/math.Pi, math.Abs(angleDeg))
+		case <-ticker.C:
+			yaw, err := r.currentYaw(ctx)
+			if err != nil {
+				return err
+			}
+			// only progress in the commanded direction counts towards the target
+			turned += direction * yawDelta(prevYaw, yaw)
+			prevYaw = yaw
+			if moveDone(turned, target, r.angularStoppingDistance()) {
+				return nil
+			}
+		}
+	}
 }
 
 func (r *RosBase) SetPower(
-	_ context.Context,
+	ctx context.Context,
 	linear r3.Vector,
 	angular r3.Vector,
 	_ map[string]interface{},
 ) error {
+	r.opMgr.CancelRunning(ctx)
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.twistMsg = &geometry_msgs.Twist{
-		Linear:  geometry_msgs.Vector3{X: linear.Y, Y: 0.0, Z: 0.0},
-		Angular: geometry_msgs.Vector3{X: 0.0, Y: 0.0, Z: angular.Z},
-	}
-	r.moving = true
+	// power fractions scale to the max speeds
+	maxLinear, maxAngular := r.limits.powerScale()
+	r.setCommandedTwist(r.axes.toTwist(linear.Mul(maxLinear), angular.Mul(maxAngular)))
 	return nil
 }
 
 func (r *RosBase) SetVelocity(
-	_ context.Context,
+	ctx context.Context,
 	linear r3.Vector,
 	angular r3.Vector,
 	_ map[string]interface{},
 ) error {
+	r.opMgr.CancelRunning(ctx)
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.twistMsg = &geometry_msgs.Twist{
-		Linear:  geometry_msgs.Vector3{X: linear.Y, Y: 0.0, Z: 0.0},
-		Angular: geometry_msgs.Vector3{X: 0.0, Y: 0.0, Z: angular.Z},
-	}
-	r.moving = true
+	// viam sends mm/s and deg/s, ROS expects m/s and rad/s
+	r.setCommandedTwist(r.axes.toTwist(linear.Mul(1/1000.0), angular.Mul(math.Pi/180.0)))
 	return nil
 }
 
-func (r *RosBase) Stop(_ context.Context, _ map[string]interface{}) error {
+func (r *RosBase) Stop(ctx context.Context, _ map[string]interface{}) error {
+	r.opMgr.CancelRunning(ctx)
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.twistMsg = &geometry_msgs.Twist{
+	r.setTwist(&geometry_msgs.Twist{
 		Linear:  geometry_msgs.Vector3{X: 0.0, Y: 0.0, Z: 0.0},
 		Angular: geometry_msgs.Vector3{X: 0.0, Y: 0.0, Z: 0.0},
-	}
-	r.moving = false
+	}, false)
 	return nil
 }
 
+// IsMoving reports the measured motion when moving_from_odometry is set and odometry is
+// recent, otherwise if a command is moving the base
 func (r *RosBase) IsMoving(_ context.Context) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.movingFromOdom {
+		if moving, ok := r.measuredMoving(time.Now()); ok {
+			return moving, nil
+		}
+	}
 	return r.moving, nil
 }
 
-func (r *RosBase) Close(_ context.Context) error {
+func (r *RosBase) Close(ctx context.Context) error {
+	r.opMgr.CancelRunning(ctx)
 	atomic.StoreInt32(&r.closed, 1)
 	r.publisher.Close()
+	if r.odomSubscriber != nil {
+		r.odomSubscriber.Close()
+	}
 	return nil
 }
 
@@ -209,13 +437,13 @@ func (r *RosBase) Properties(
 	_ context.Context,
 	_ map[string]interface{},
 ) (viambase.Properties, error) {
-	return viambase.Properties{
-		TurningRadiusMeters: 0.0,
-		WidthMeters:         0.0,
-	}, nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.properties, nil
 }
 
 func (r *RosBase) Geometries(_ context.Context, _ map[string]interface{}) ([]spatialmath.Geometry, error) {
-	r.logger.Warn("Geometries Not Implemented")
-	return nil, nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.geometries, nil
 }
@@ -3,10 +3,38 @@ package base
 import "fmt"
 
 type RosBaseConfig struct {
-	NodeName   string `json:"node_name"`
-	PrimaryUri string `json:"primary_uri"`
-	Topic      string `json:"topic"`
-	TimeRate   int64  `json:"time_rate_ms"` // in ms
+	NodeName    string `json:"node_name"`
+	PrimaryUri  string `json:"primary_uri"`
+	Topic       string `json:"topic"`
+	TimeRate    int64  `json:"time_rate_ms"` // in ms
+	OdomTopic   string `json:"odom_topic"`   // nav_msgs/Odometry, optional
+	Imu         string `json:"imu"`          // movement sensor used for spins, optional
+	PublishMode string `json:"publish_mode"` // continuous, on_change or until_stopped
+	// stop when no SetPower/SetVelocity arrives in time, 0 disables
+	CommandTimeout int64 `json:"command_timeout_ms"` // in ms
+	// velocity and acceleration limits, 0 disables
+	MaxLinear       float64 `json:"max_linear_mps"`
+	MaxAngular      float64 `json:"max_angular_dps"`
+	MaxLinearAccel  float64 `json:"max_linear_accel_mps2"`
+	MaxAngularAccel float64 `json:"max_angular_accel_dps2"`
+	// viam axis (linear_x ... angular_z) to twist field, defaults to linear_y->linear_x, angular_z->angular_z
+	AxisMapping map[string]AxisMap `json:"axis_mapping"`
+	// footprint, anything not set is read from the URDF when robot_description_param is set
+	Width              float64    `json:"width_m"`
+	TurningRadius      float64    `json:"turning_radius_m"`
+	WheelCircumference float64    `json:"wheel_circumference_m"`
+	Geometry           *BoxConfig `json:"geometry"`
+	RobotDescription   string     `json:"robot_description_param"` // i.e. /robot_description
+	BaseLink           string     `json:"base_link"`               // defaults to base_link
+	// outgoing message, twist (default), twist_stamped or ackermann
+	MessageType      string  `json:"message_type"`
+	FrameId          string  `json:"frame_id"`               // stamped messages, defaults to base_link
+	Wheelbase        float64 `json:"wheelbase_m"`            // ackermann only
+	MaxSteeringAngle float64 `json:"max_steering_angle_deg"` // ackermann only
+	// IsMoving from the odom_topic twist instead of the last command
+	MovingFromOdometry bool    `json:"moving_from_odometry"`
+	MovingLinear       float64 `json:"moving_linear_threshold_mps"`  // defaults to 0.01
+	MovingAngular      float64 `json:"moving_angular_threshold_dps"` // defaults to 1
 }
 
 func (cfg *RosBaseConfig) Validate(path string) ([]string, error) {
@@ -19,5 +47,56 @@ func (cfg *RosBaseConfig) Validate(path string) ([]string, error) {
 		return nil, fmt.Errorf(`expted "RosTopic" attribute for sensor %q`, path)
 	}
 
+	if cfg.CommandTimeout < 0 {
+		return nil, fmt.Errorf(`"command_timeout_ms" must not be negative for base %q`, path)
+	}
+
+	if cfg.MaxLinear < 0 || cfg.MaxAngular < 0 || cfg.MaxLinearAccel < 0 || cfg.MaxAngularAccel < 0 {
+		return nil, fmt.Errorf(`velocity and acceleration limits must not be negative for base %q`, path)
+	}
+
+	if cfg.Width < 0 || cfg.TurningRadius < 0 || cfg.WheelCircumference < 0 {
+		return nil, fmt.Errorf(`"width_m", "turning_radius_m" and "wheel_circumference_m" must not be negative for base %q`, path)
+	}
+
+	if cfg.Geometry != nil && (cfg.Geometry.X <= 0 || cfg.Geometry.Y <= 0 || cfg.Geometry.Z <= 0) {
+		return nil, fmt.Errorf(`"geometry" dimensions must be positive for base %q`, path)
+	}
+
+	if err := axisMapping(cfg.AxisMapping).validate(); err != nil {
+		return nil, fmt.Errorf("%w for base %q", err, path)
+	}
+
+	switch cfg.PublishMode {
+	case "", PublishContinuous, PublishOnChange, PublishUntilStopped:
+	default:
+		return nil, fmt.Errorf(`unknown "publish_mode" %q for base %q`, cfg.PublishMode, path)
+	}
+
+	if cfg.MovingFromOdometry && cfg.OdomTopic == "" {
+		return nil, fmt.Errorf(`"moving_from_odometry" requires "odom_topic" for base %q`, path)
+	}
+
+	if cfg.MovingLinear < 0 || cfg.MovingAngular < 0 {
+		return nil, fmt.Errorf(`moving thresholds must not be negative for base %q`, path)
+	}
+
+	switch cfg.MessageType {
+	case "", MessageTwist, MessageTwistStamped:
+	case MessageAckermann:
+		if cfg.Wheelbase <= 0 {
+			return nil, fmt.Errorf(`"wheelbase_m" must be set for ackermann base %q`, path)
+		}
+		if cfg.MaxSteeringAngle < 0 || cfg.MaxSteeringAngle >= 90 {
+			return nil, fmt.Errorf(`"max_steering_angle_deg" must be between 0 and 90 for base %q`, path)
+		}
+	default:
+		return nil, fmt.Errorf(`unknown "message_type" %q for base %q`, cfg.MessageType, path)
+	}
+
+	if cfg.Imu != "" {
+		return []string{cfg.Imu}, nil
+	}
+
 	return nil, nil
 }
@@ -0,0 +1,294 @@
+package base
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/nav_msgs"
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+func odomAt(x, y float64) *nav_msgs.Odometry {
+	msg := &nav_msgs.Odometry{}
+	msg.Pose.Pose.Position = geometry_msgs.Point{X: x, Y: y}
+	msg.Pose.Pose.Orientation = geometry_msgs.Quaternion{W: 1}
+	return msg
+}
+
+func TestDistanceTraveled(t *testing.T) {
+	test.That(t, distanceTraveled(odomAt(1, 1), odomAt(1, 1)), test.ShouldEqual, 0)
+	test.That(t, distanceTraveled(odomAt(0, 0), odomAt(3, 4)), test.ShouldAlmostEqual, 5)
+	test.That(t, distanceTraveled(odomAt(1, 2), odomAt(-2, -2)), test.ShouldAlmostEqual, 5)
+}
+
+func TestYawDelta(t *testing.T) {
+	test.That(t, yawDelta(0.1, 0.3), test.ShouldAlmostEqual, 0.2)
+	test.That(t, yawDelta(0.3, 0.1), test.ShouldAlmostEqual, -0.2)
+	// crossing +/- pi in either direction
+	test.That(t, yawDelta(math.Pi-0.1, -math.Pi+0.1), test.ShouldAlmostEqual, 0.2)
+	test.That(t, yawDelta(-math.Pi+0.1, math.Pi-0.1), test.ShouldAlmostEqual, -0.2)
+}
+
+func TestYawFromQuaternion(t *testing.T) {
+	// 90 degrees about z
+	q := geometry_msgs.Quaternion{W: math.Cos(math.Pi / 4), Z: math.Sin(math.Pi / 4)}
+	test.That(t, yawFromQuaternion(q), test.ShouldAlmostEqual, math.Pi/2)
+	test.That(t, yawFromQuaternion(geometry_msgs.Quaternion{W: 1}), test.ShouldAlmostEqual, 0)
+}
+
+// newTestBase returns a base with no ROS connection for exercising the publish loop
+func newTestBase(t *testing.T, mode string) *RosBase {
+	return &RosBase{
+		logger:      logging.NewTestLogger(t),
+		publishMode: mode,
+		timeRate:    100 * time.Millisecond,
+		twistMsg:    &geometry_msgs.Twist{},
+		outputTwist: &geometry_msgs.Twist{},
+	}
+}
+
+func countPublishes(r *RosBase, ticks int) int {
+	n := 0
+	for i := 0; i < ticks; i++ {
+		if _, ok := r.publishTick(time.Now()); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPublishModes(t *testing.T) {
+	moving := &geometry_msgs.Twist{Linear: geometry_msgs.Vector3{X: 0.2}}
+	stopped := &geometry_msgs.Twist{}
+
+	r := newTestBase(t, PublishContinuous)
+	r.setTwist(stopped, false)
+	test.That(t, countPublishes(r, 10), test.ShouldEqual, 10)
+
+	r = newTestBase(t, PublishOnChange)
+	r.setTwist(moving, true)
+	test.That(t, countPublishes(r, 10), test.ShouldEqual, 1)
+	r.setTwist(stopped, false)
+	test.That(t, countPublishes(r, 10), test.ShouldEqual, 1)
+
+	r = newTestBase(t, PublishUntilStopped)
+	r.setTwist(moving, true)
+	test.That(t, countPublishes(r, 10), test.ShouldEqual, 10)
+	r.setTwist(stopped, false)
+	test.That(t, countPublishes(r, 10), test.ShouldEqual, stopRepeatCount)
+}
+
+func TestCommandTimeout(t *testing.T) {
+	r := newTestBase(t, PublishContinuous)
+	r.commandTimeout = 100 * time.Millisecond
+	r.setCommandedTwist(&geometry_msgs.Twist{Linear: geometry_msgs.Vector3{X: 0.2}})
+
+	r.checkCommandTimeout(r.lastCommand.Add(50 * time.Millisecond))
+	test.That(t, r.moving, test.ShouldBeTrue)
+	test.That(t, r.twistMsg.Linear.X, test.ShouldEqual, 0.2)
+
+	r.checkCommandTimeout(r.lastCommand.Add(150 * time.Millisecond))
+	test.That(t, r.moving, test.ShouldBeFalse)
+	test.That(t, r.twistMsg.Linear.X, test.ShouldEqual, 0)
+
+	// closed-loop moves are not watched
+	r.setTwist(&geometry_msgs.Twist{Linear: geometry_msgs.Vector3{X: 0.2}}, true)
+	r.checkCommandTimeout(time.Now().Add(time.Hour))
+	test.That(t, r.moving, test.ShouldBeTrue)
+}
+
+func TestVelocityLimits(t *testing.T) {
+	r := newTestBase(t, PublishOnChange)
+	r.limits = velocityLimits{maxLinear: 0.5, maxAngular: 1, maxLinearAccel: 1, maxAngularAccel: 2}
+
+	// commands over the limit are clamped
+	r.setTwist(&geometry_msgs.Twist{
+		Linear:  geometry_msgs.Vector3{X: -2},
+		Angular: geometry_msgs.Vector3{Z: 0.5},
+	}, true)
+	test.That(t, r.twistMsg.Linear.X, test.ShouldEqual, -0.5)
+	test.That(t, r.twistMsg.Angular.Z, test.ShouldEqual, 0.5)
+
+	// the output ramps at 0.1 m/s and 0.2 rad/s per 100ms tick
+	twist, ok := r.publishTick(time.Now())
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, twist.Linear.X, test.ShouldAlmostEqual, -0.1)
+	test.That(t, twist.Angular.Z, test.ShouldAlmostEqual, 0.2)
+
+	// ramping counts as a change until the target is reached
+	test.That(t, countPublishes(r, 10), test.ShouldEqual, 4)
+	test.That(t, r.outputTwist.Linear.X, test.ShouldAlmostEqual, -0.5)
+	test.That(t, r.outputTwist.Angular.Z, test.ShouldAlmostEqual, 0.5)
+}
+
+func TestMoveTimeout(t *testing.T) {
+	// 1m at a clamped 0.2 m/s takes 5s, twice that plus slack is allowed
+	test.That(t, moveTimeout(1, 0.2, 0), test.ShouldEqual, 12*time.Second)
+	// ramping up and down at 0.1 m/s^2 costs another 2s
+	test.That(t, moveTimeout(1, 0.2, 0.1), test.ShouldEqual, 16*time.Second)
+
+	test.That(t, stoppingDistance(0.2, 0), test.ShouldEqual, 0)
+	test.That(t, stoppingDistance(0.2, 0.1), test.ShouldAlmostEqual, 0.2)
+}
+
+func TestShortMoveStopsEarly(t *testing.T) {
+	// 0.1m at 0.5 m/s can't reach full speed with 0.5 m/s^2, the commanded stopping distance is 0.25m
+	r := newTestBase(t, PublishContinuous)
+	r.limits = velocityLimits{maxLinearAccel: 0.5}
+	r.setTwist(&geometry_msgs.Twist{Linear: geometry_msgs.Vector3{X: 0.5}}, true)
+	const target = 0.1
+
+	test.That(t, moveDone(0, target, stoppingDistance(0.5, 0.5)), test.ShouldBeFalse)
+	test.That(t, moveDone(0, target, r.linearStoppingDistance()), test.ShouldBeFalse)
+
+	// integrate the published speed until the move is done, then ramp down
+	traveled := 0.0
+	dt := r.timeRate.Seconds()
+	for i := 0; i < 100 && !moveDone(traveled, target, r.linearStoppingDistance()); i++ {
+		twist, _ := r.publishTick(time.Now())
+		traveled += twist.Linear.X * dt
+	}
+	test.That(t, traveled, test.ShouldBeGreaterThan, 0)
+	test.That(t, r.outputTwist.Linear.X, test.ShouldBeLessThan, 0.5)
+
+	r.setTwist(&geometry_msgs.Twist{}, false)
+	for i := 0; i < 100 && r.outputTwist.Linear.X > 0; i++ {
+		twist, _ := r.publishTick(time.Now())
+		traveled += twist.Linear.X * dt
+	}
+	test.That(t, traveled, test.ShouldAlmostEqual, target, 0.05)
+}
+
+func TestPowerScale(t *testing.T) {
+	linear, angular := velocityLimits{}.powerScale()
+	test.That(t, linear, test.ShouldEqual, 1.0)
+	test.That(t, angular, test.ShouldEqual, 1.0)
+
+	linear, angular = velocityLimits{maxLinear: 0.4, maxAngular: math.Pi}.powerScale()
+	test.That(t, linear, test.ShouldEqual, 0.4)
+	test.That(t, angular, test.ShouldEqual, math.Pi)
+}
+
+func TestAxisMapping(t *testing.T) {
+	twist := defaultAxisMapping.toTwist(r3.Vector{X: 1, Y: 0.5}, r3.Vector{Z: 0.3})
+	test.That(t, twist.Linear.X, test.ShouldEqual, 0.5)
+	test.That(t, twist.Linear.Y, test.ShouldEqual, 0)
+	test.That(t, twist.Angular.Z, test.ShouldEqual, 0.3)
+
+	// mecanum: viam x (right) is ROS -y (left)
+	holonomic := axisMapping{
+		axisLinearX:  {Twist: axisLinearY, Sign: -1},
+		axisLinearY:  {Twist: axisLinearX},
+		axisAngularZ: {Twist: axisAngularZ, Scale: 2},
+	}
+	test.That(t, holonomic.validate(), test.ShouldBeNil)
+	twist = holonomic.toTwist(r3.Vector{X: 1, Y: 0.5}, r3.Vector{Z: 0.3})
+	test.That(t, twist.Linear.X, test.ShouldEqual, 0.5)
+	test.That(t, twist.Linear.Y, test.ShouldEqual, -1)
+	test.That(t, twist.Angular.Z, test.ShouldAlmostEqual, 0.6)
+
+	test.That(t, axisMapping{"forward": {Twist: axisLinearX}}.validate(), test.ShouldNotBeNil)
+	test.That(t, axisMapping{axisLinearX: {Twist: "x"}}.validate(), test.ShouldNotBeNil)
+	test.That(t, axisMapping{axisLinearX: {Twist: axisLinearX, Sign: 2}}.validate(), test.ShouldNotBeNil)
+}
+
+const testURDF = `<?xml version="1.0"?>
+<robot name="transbot">
+  <link name="base_link">
+    <collision>
+      <origin xyz="0.01 0 0.05" rpy="0 0 0"/>
+      <geometry><box size="0.30 0.20 0.10"/></geometry>
+    </collision>
+  </link>
+  <link name="left_wheel">
+    <collision><geometry><cylinder radius="0.035" length="0.02"/></geometry></collision>
+  </link>
+  <link name="right_wheel">
+    <collision><geometry><cylinder radius="0.035" length="0.02"/></geometry></collision>
+  </link>
+  <joint name="left_wheel_joint" type="continuous">
+    <parent link="base_link"/>
+    <child link="left_wheel"/>
+    <origin xyz="0 0.09 0" rpy="0 0 0"/>
+  </joint>
+  <joint name="right_wheel_joint" type="continuous">
+    <parent link="base_link"/>
+    <child link="right_wheel"/>
+    <origin xyz="0 -0.09 0" rpy="0 0 0"/>
+  </joint>
+</robot>`
+
+func TestRobotDescription(t *testing.T) {
+	robot, err := parseRobotDescription(testURDF)
+	test.That(t, err, test.ShouldBeNil)
+
+	props, err := robot.properties("base_link")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.WidthMeters, test.ShouldAlmostEqual, 0.18)
+	test.That(t, props.WheelCircumferenceMeters, test.ShouldAlmostEqual, 2*math.Pi*0.035)
+
+	geometries, err := robot.geometries("base_link")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(geometries), test.ShouldEqual, 1)
+	// ROS x forward becomes viam y forward
+	box := geometries[0].ToProtobuf().GetBox()
+	test.That(t, box.DimsMm.X, test.ShouldAlmostEqual, 200)
+	test.That(t, box.DimsMm.Y, test.ShouldAlmostEqual, 300)
+	test.That(t, geometries[0].Pose().Point().Y, test.ShouldAlmostEqual, 10)
+	test.That(t, geometries[0].Pose().Point().Z, test.ShouldAlmostEqual, 50)
+
+	_, err = robot.geometries("missing_link")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestAckermann(t *testing.T) {
+	// 1 m/s at 0.5 rad/s with a 0.3m wheelbase
+	drive := twistToAckermann(&geometry_msgs.Twist{
+		Linear:  geometry_msgs.Vector3{X: 1},
+		Angular: geometry_msgs.Vector3{Z: 0.5},
+	}, 0.3, 0)
+	test.That(t, drive.Speed, test.ShouldEqual, 1)
+	test.That(t, drive.SteeringAngle, test.ShouldAlmostEqual, math.Atan(0.15), 1e-6)
+
+	// steering is bounded and straight when stopped
+	drive = twistToAckermann(&geometry_msgs.Twist{
+		Linear:  geometry_msgs.Vector3{X: 0.1},
+		Angular: geometry_msgs.Vector3{Z: 2},
+	}, 0.3, math.Pi/6)
+	test.That(t, drive.SteeringAngle, test.ShouldAlmostEqual, math.Pi/6, 1e-6)
+	drive = twistToAckermann(&geometry_msgs.Twist{Angular: geometry_msgs.Vector3{Z: 2}}, 0.3, math.Pi/6)
+	test.That(t, drive.SteeringAngle, test.ShouldEqual, 0)
+
+	test.That(t, ackermannTurningRadius(0.3, math.Pi/4), test.ShouldAlmostEqual, 0.3)
+	test.That(t, ackermannTurningRadius(0.3, 0), test.ShouldEqual, 0)
+}
+
+func TestMeasuredMoving(t *testing.T) {
+	r := newTestBase(t, PublishContinuous)
+	r.movingLinear = 0.01
+	r.movingAngular = 0.02
+	now := time.Now()
+
+	_, ok := r.measuredMoving(now)
+	test.That(t, ok, test.ShouldBeFalse)
+
+	r.processOdometry(odomAt(0, 0))
+	moving, ok := r.measuredMoving(now)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, moving, test.ShouldBeFalse)
+
+	msg := odomAt(0, 0)
+	msg.Twist.Twist.Angular.Z = 0.1
+	r.processOdometry(msg)
+	moving, ok = r.measuredMoving(now)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, moving, test.ShouldBeTrue)
+
+	// stale odometry falls back to the command flag
+	_, ok = r.measuredMoving(now.Add(2 * odomStaleTimeout))
+	test.That(t, ok, test.ShouldBeFalse)
+}
@@ -0,0 +1,284 @@
+package base
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/geo/r3"
+	viambase "go.viam.com/rdk/components/base"
+	"go.viam.com/rdk/spatialmath"
+)
+
+/*
+ * Base footprint
+ * Properties and Geometries come from the width_m, turning_radius_m,
+ * wheel_circumference_m and geometry attributes. Anything not configured
+ * can be read from the URDF in the robot_description parameter:
+ * the collision geometry of the base link and the separation of
+ * the continuous (wheel) joints attached to it.
+ *
+ * ROS uses x forward and y left, the viam base frame uses y forward
+ * and x right, so URDF values are rotated into the viam frame.
+ */
+
+// BoxConfig is a box geometry centered on the base in mm, x is width and y is length
+type BoxConfig struct {
+	X float64 `json:"x_mm"`
+	Y float64 `json:"y_mm"`
+	Z float64 `json:"z_mm"`
+}
+
+type urdfRobot struct {
+	Links  []urdfLink  `xml:"link"`
+	Joints []urdfJoint `xml:"joint"`
+}
+
+type urdfLink struct {
+	Name      string          `xml:"name,attr"`
+	Collision []urdfCollision `xml:"collision"`
+}
+
+type urdfCollision struct {
+	Origin   urdfOrigin   `xml:"origin"`
+	Geometry urdfGeometry `xml:"geometry"`
+}
+
+type urdfGeometry struct {
+	Box *struct {
+		Size string `xml:"size,attr"`
+	} `xml:"box"`
+	Cylinder *struct {
+		Radius float64 `xml:"radius,attr"`
+		Length float64 `xml:"length,attr"`
+	} `xml:"cylinder"`
+	Sphere *struct {
+		Radius float64 `xml:"radius,attr"`
+	} `xml:"sphere"`
+}
+
+type urdfOrigin struct {
+	XYZ string `xml:"xyz,attr"`
+	RPY string `xml:"rpy,attr"`
+}
+
+type urdfJoint struct {
+	Name   string     `xml:"name,attr"`
+	Type   string     `xml:"type,attr"`
+	Origin urdfOrigin `xml:"origin"`
+	Parent struct {
+		Link string `xml:"link,attr"`
+	} `xml:"parent"`
+	Child struct {
+		Link string `xml:"link,attr"`
+	} `xml:"child"`
+}
+
+// parseTriple parses a URDF "x y z" attribute, empty values are all zero
+func parseTriple(s string) (r3.Vector, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return r3.Vector{}, nil
+	}
+	if len(fields) != 3 {
+		return r3.Vector{}, fmt.Errorf("expected 3 values, got %q", s)
+	}
+	var v [3]float64
+	for i, f := range fields {
+		var err error
+		if v[i], err = strconv.ParseFloat(f, 64); err != nil {
+			return r3.Vector{}, err
+		}
+	}
+	return r3.Vector{X: v[0], Y: v[1], Z: v[2]}, nil
+}
+
+// rosToViam converts a ROS position in meters to a viam base frame position in mm
+func rosToViam(v r3.Vector) r3.Vector {
+	return r3.Vector{X: -v.Y * 1000, Y: v.X * 1000, Z: v.Z * 1000}
+}
+
+func (u *urdfRobot) link(name string) *urdfLink {
+	for i := range u.Links {
+		if u.Links[i].Name == name {
+			return &u.Links[i]
+		}
+	}
+	return nil
+}
+
+// geometries returns the collision geometry of the link in the viam base frame
+func (u *urdfRobot) geometries(linkName string) ([]spatialmath.Geometry, error) {
+	link := u.link(linkName)
+	if link == nil {
+		return nil, fmt.Errorf("link %q not found in robot description", linkName)
+	}
+
+	var geometries []spatialmath.Geometry
+	for i, c := range link.Collision {
+		xyz, err := parseTriple(c.Origin.XYZ)
+		if err != nil {
+			return nil, err
+		}
+		rpy, err := parseTriple(c.Origin.RPY)
+		if err != nil {
+			return nil, err
+		}
+		// only yaw is kept, rotating about z is the same in both frames
+		pose := spatialmath.NewPose(rosToViam(xyz), &spatialmath.EulerAngles{Yaw: rpy.Z})
+		label := fmt.Sprintf("%s_%d", linkName, i)
+
+		var g spatialmath.Geometry
+		switch {
+		case c.Geometry.Box != nil:
+			size, err := parseTriple(c.Geometry.Box.Size)
+			if err != nil {
+				return nil, err
+			}
+			g, err = spatialmath.NewBox(pose, r3.Vector{X: size.Y * 1000, Y: size.X * 1000, Z: size.Z * 1000}, label)
+			if err != nil {
+				return nil, err
+			}
+		case c.Geometry.Cylinder != nil:
+			// bounding box of an upright cylinder
+			d := c.Geometry.Cylinder.Radius * 2000
+			g, err = spatialmath.NewBox(pose, r3.Vector{X: d, Y: d, Z: c.Geometry.Cylinder.Length * 1000}, label)
+			if err != nil {
+				return nil, err
+			}
+		case c.Geometry.Sphere != nil:
+			g, err = spatialmath.NewSphere(pose, c.Geometry.Sphere.Radius*1000, label)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		geometries = append(geometries, g)
+	}
+	return geometries, nil
+}
+
+// properties returns the wheel separation and wheel circumference of the continuous joints on the link,
+// falling back to the width of the collision geometry when there are no wheels
+func (u *urdfRobot) properties(linkName string) (viambase.Properties, error) {
+	props := viambase.Properties{}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	wheels := 0
+	for _, j := range u.Joints {
+		if j.Type != "continuous" || j.Parent.Link != linkName {
+			continue
+		}
+		xyz, err := parseTriple(j.Origin.XYZ)
+		if err != nil {
+			return props, err
+		}
+		minY, maxY = math.Min(minY, xyz.Y), math.Max(maxY, xyz.Y)
+		wheels++
+
+		if wheel := u.link(j.Child.Link); wheel != nil && props.WheelCircumferenceMeters == 0 {
+			for _, c := range wheel.Collision {
+				if c.Geometry.Cylinder != nil {
+					props.WheelCircumferenceMeters = 2 * math.Pi * c.Geometry.Cylinder.Radius
+					break
+				}
+			}
+		}
+	}
+	if wheels >= 2 {
+		props.WidthMeters = maxY - minY
+		return props, nil
+	}
+
+	link := u.link(linkName)
+	if link == nil {
+		return props, fmt.Errorf("link %q not found in robot description", linkName)
+	}
+	for _, c := range link.Collision {
+		switch {
+		case c.Geometry.Box != nil:
+			size, err := parseTriple(c.Geometry.Box.Size)
+			if err != nil {
+				return props, err
+			}
+			props.WidthMeters = math.Max(props.WidthMeters, size.Y)
+		case c.Geometry.Cylinder != nil:
+			props.WidthMeters = math.Max(props.WidthMeters, 2*c.Geometry.Cylinder.Radius)
+		case c.Geometry.Sphere != nil:
+			props.WidthMeters = math.Max(props.WidthMeters, 2*c.Geometry.Sphere.Radius)
+		}
+	}
+	return props, nil
+}
+
+// loadFootprint builds the properties and geometries from the config, filling anything
+// not configured from the URDF when robot_description_param is set
+func (r *RosBase) loadFootprint(cfg *RosBaseConfig) (viambase.Properties, []spatialmath.Geometry, error) {
+	props := viambase.Properties{
+		TurningRadiusMeters:      cfg.TurningRadius,
+		WidthMeters:              cfg.Width,
+		WheelCircumferenceMeters: cfg.WheelCircumference,
+	}
+
+	if props.TurningRadiusMeters == 0 && cfg.MessageType == MessageAckermann {
+		props.TurningRadiusMeters = ackermannTurningRadius(cfg.Wheelbase, cfg.MaxSteeringAngle*math.Pi/180.0)
+	}
+
+	var geometries []spatialmath.Geometry
+	if cfg.Geometry != nil {
+		box, err := spatialmath.NewBox(
+			spatialmath.NewZeroPose(),
+			r3.Vector{X: cfg.Geometry.X, Y: cfg.Geometry.Y, Z: cfg.Geometry.Z},
+			r.Name().ShortName(),
+		)
+		if err != nil {
+			return props, nil, err
+		}
+		geometries = append(geometries, box)
+	}
+
+	if len(strings.TrimSpace(cfg.RobotDescription)) == 0 {
+		return props, geometries, nil
+	}
+
+	description, err := r.node.ParamGetString(cfg.RobotDescription)
+	if err != nil {
+		return props, nil, err
+	}
+	robot, err := parseRobotDescription(description)
+	if err != nil {
+		return props, nil, err
+	}
+
+	baseLink := cfg.BaseLink
+	if baseLink == "" {
+		baseLink = "base_link"
+	}
+	if geometries == nil {
+		if geometries, err = robot.geometries(baseLink); err != nil {
+			return props, nil, err
+		}
+	}
+	urdfProps, err := robot.properties(baseLink)
+	if err != nil {
+		return props, nil, err
+	}
+	if props.WidthMeters == 0 {
+		props.WidthMeters = urdfProps.WidthMeters
+	}
+	if props.WheelCircumferenceMeters == 0 {
+		props.WheelCircumferenceMeters = urdfProps.WheelCircumferenceMeters
+	}
+	return props, geometries, nil
+}
+
+// parseRobotDescription reads the URDF xml
+func parseRobotDescription(description string) (*urdfRobot, error) {
+	robot := &urdfRobot{}
+	if err := xml.Unmarshal([]byte(description), robot); err != nil {
+		return nil, err
+	}
+	return robot, nil
+}
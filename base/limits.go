@@ -0,0 +1,75 @@
+package base
+
+import (
+	"math"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+)
+
+// velocityLimits bounds the commanded twist and how fast the published twist may change.
+// linear values are in m/s (m/s^2), angular values in rad/s (rad/s^2), zero means unlimited.
+type velocityLimits struct {
+	maxLinear       float64
+	maxAngular      float64
+	maxLinearAccel  float64
+	maxAngularAccel float64
+}
+
+// powerScale returns the speeds full power maps to, falling back to 1 m/s and 1 rad/s when unlimited
+func (l velocityLimits) powerScale() (float64, float64) {
+	linear, angular := l.maxLinear, l.maxAngular
+	if linear <= 0 {
+		linear = 1.0
+	}
+	if angular <= 0 {
+		angular = 1.0
+	}
+	return linear, angular
+}
+
+// clamp bounds each twist component to the max velocities and reports if anything was clamped
+func (l velocityLimits) clamp(twist *geometry_msgs.Twist) (*geometry_msgs.Twist, bool) {
+	var clamped bool
+	out := &geometry_msgs.Twist{}
+	out.Linear.X, clamped = clampComponent(twist.Linear.X, l.maxLinear, clamped)
+	out.Linear.Y, clamped = clampComponent(twist.Linear.Y, l.maxLinear, clamped)
+	out.Linear.Z, clamped = clampComponent(twist.Linear.Z, l.maxLinear, clamped)
+	out.Angular.X, clamped = clampComponent(twist.Angular.X, l.maxAngular, clamped)
+	out.Angular.Y, clamped = clampComponent(twist.Angular.Y, l.maxAngular, clamped)
+	out.Angular.Z, clamped = clampComponent(twist.Angular.Z, l.maxAngular, clamped)
+	return out, clamped
+}
+
+// ramp moves current towards target by at most the acceleration limits over dt seconds
+func (l velocityLimits) ramp(current, target *geometry_msgs.Twist, dt float64) *geometry_msgs.Twist {
+	return &geometry_msgs.Twist{
+		Linear: geometry_msgs.Vector3{
+			X: rampComponent(current.Linear.X, target.Linear.X, l.maxLinearAccel*dt),
+			Y: rampComponent(current.Linear.Y, target.Linear.Y, l.maxLinearAccel*dt),
+			Z: rampComponent(current.Linear.Z, target.Linear.Z, l.maxLinearAccel*dt),
+		},
+		Angular: geometry_msgs.Vector3{
+			X: rampComponent(current.Angular.X, target.Angular.X, l.maxAngularAccel*dt),
+			Y: rampComponent(current.Angular.Y, target.Angular.Y, l.maxAngularAccel*dt),
+			Z: rampComponent(current.Angular.Z, target.Angular.Z, l.maxAngularAccel*dt),
+		},
+	}
+}
+
+func clampComponent(v, max float64, clamped bool) (float64, bool) {
+	if max <= 0 || math.Abs(v) <= max {
+		return v, clamped
+	}
+	return math.Copysign(max, v), true
+}
+
+func rampComponent(current, target, step float64) float64 {
+	if step <= 0 || math.Abs(target-current) <= step {
+		return target
+	}
+	return current + math.Copysign(step, target-current)
+}
+
+func isZeroTwist(twist *geometry_msgs.Twist) bool {
+	return *twist == geometry_msgs.Twist{}
+}
@@ -0,0 +1,78 @@
+package base
+
+import (
+	"math"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/ackermann_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/std_msgs"
+)
+
+// outgoing message types selected by message_type
+const (
+	// MessageTwist publishes geometry_msgs/Twist
+	MessageTwist = "twist"
+	// MessageTwistStamped publishes geometry_msgs/TwistStamped with frame_id
+	MessageTwistStamped = "twist_stamped"
+	// MessageAckermann publishes ackermann_msgs/AckermannDriveStamped with frame_id
+	MessageAckermann = "ackermann"
+)
+
+// newOutputMessage returns an empty message of the given type for the publisher
+func newOutputMessage(messageType string) interface{} {
+	switch messageType {
+	case MessageTwistStamped:
+		return &geometry_msgs.TwistStamped{}
+	case MessageAckermann:
+		return &ackermann_msgs.AckermannDriveStamped{}
+	default:
+		return &geometry_msgs.Twist{}
+	}
+}
+
+// toOutputMessage converts the twist to the configured message type, caller must hold r.mu
+func (r *RosBase) toOutputMessage(twist *geometry_msgs.Twist, now time.Time) interface{} {
+	switch r.messageType {
+	case MessageTwistStamped:
+		r.seq++
+		return &geometry_msgs.TwistStamped{
+			Header: std_msgs.Header{Seq: r.seq, Stamp: now, FrameId: r.frameId},
+			Twist:  *twist,
+		}
+	case MessageAckermann:
+		r.seq++
+		return &ackermann_msgs.AckermannDriveStamped{
+			Header: std_msgs.Header{Seq: r.seq, Stamp: now, FrameId: r.frameId},
+			Drive:  twistToAckermann(twist, r.wheelbase, r.maxSteeringAngle),
+		}
+	default:
+		return twist
+	}
+}
+
+// twistToAckermann uses the bicycle model to turn a forward speed and yaw rate into
+// a speed and steering angle, bounded by maxSteering radians (0 is unbounded)
+func twistToAckermann(twist *geometry_msgs.Twist, wheelbase, maxSteering float64) ackermann_msgs.AckermannDrive {
+	speed := twist.Linear.X
+	steering := 0.0
+	// a car can not turn in place, keep the wheels straight when stopped
+	if speed != 0 && twist.Angular.Z != 0 {
+		steering = math.Atan(wheelbase * twist.Angular.Z / speed)
+	}
+	if maxSteering > 0 && math.Abs(steering) > maxSteering {
+		steering = math.Copysign(maxSteering, steering)
+	}
+	return ackermann_msgs.AckermannDrive{
+		Speed:         float32(speed),
+		SteeringAngle: float32(steering),
+	}
+}
+
+// ackermannTurningRadius returns the minimum turning radius in meters of an ackermann base
+func ackermannTurningRadius(wheelbase, maxSteering float64) float64 {
+	if wheelbase <= 0 || maxSteering <= 0 {
+		return 0
+	}
+	return wheelbase / math.Tan(maxSteering)
+}
@@ -0,0 +1,165 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/nav_msgs"
+	"go.viam.com/rdk/spatialmath"
+)
+
+const (
+	// odomPollRate is how often closed-loop moves check the latest odometry
+	odomPollRate = 20 * time.Millisecond
+	// odomWaitTimeout is how long we wait for a first odometry message
+	odomWaitTimeout = 2 * time.Second
+	// moves are allowed to take moveTimeoutFactor times the expected time (plus slack)
+	moveTimeoutFactor = 2.0
+	moveTimeoutSlack  = 2 * time.Second
+	// odometry older than odomStaleTimeout is not used for IsMoving
+	odomStaleTimeout = time.Second
+)
+
+func (r *RosBase) processOdometry(msg *nav_msgs.Odometry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.odomMsg = msg
+	r.odomReceived = time.Now()
+}
+
+func (r *RosBase) latestOdometry() *nav_msgs.Odometry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.odomMsg
+}
+
+// waitForOdometry returns the latest odometry message, waiting briefly if none has arrived yet
+func (r *RosBase) waitForOdometry(ctx context.Context) (*nav_msgs.Odometry, error) {
+	r.mu.Lock()
+	subscribed := r.odomSubscriber != nil
+	topic := r.odomTopic
+	r.mu.Unlock()
+
+	if !subscribed {
+		return nil, fmt.Errorf("odom_topic must be set for closed-loop moves")
+	}
+
+	timeout := time.NewTimer(odomWaitTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(odomPollRate)
+	defer ticker.Stop()
+
+	for {
+		if msg := r.latestOdometry(); msg != nil {
+			return msg, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return nil, fmt.Errorf("no odometry received on %s", topic)
+		case <-ticker.C:
+		}
+	}
+}
+
+// moveTimeout returns how long a move of the given size at the given (clamped) rate may take,
+// an acceleration limit adds the time lost ramping up and down
+func moveTimeout(amount, rate, accel float64) time.Duration {
+	expected := math.Abs(amount) / math.Abs(rate)
+	if accel > 0 {
+		expected += math.Abs(rate) / accel
+	}
+	return time.Duration(moveTimeoutFactor*expected*float64(time.Second)) + moveTimeoutSlack
+}
+
+// stoppingDistance returns how far the base keeps going while the published twist ramps
+// down from rate, closed-loop moves stop this early so they don't overshoot
+func stoppingDistance(rate, accel float64) float64 {
+	if accel <= 0 {
+		return 0
+	}
+	return rate * rate / (2 * accel)
+}
+
+// linearStoppingDistance returns the stopping distance in m from the speed being published,
+// which is below the commanded speed while ramping up
+func (r *RosBase) linearStoppingDistance() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l := r.outputTwist.Linear
+	return stoppingDistance(math.Sqrt(l.X*l.X+l.Y*l.Y+l.Z*l.Z), r.limits.maxLinearAccel)
+}
+
+// angularStoppingDistance returns the stopping angle in rad from the turn rate being published
+func (r *RosBase) angularStoppingDistance() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return stoppingDistance(math.Abs(r.outputTwist.Angular.Z), r.limits.maxAngularAccel)
+}
+
+// moveDone reports if a closed-loop move should stop, early by the stopping distance
+// but never before the base has moved at all
+func moveDone(progress, target, stopping float64) bool {
+	return progress >= target || (progress > 0 && progress+stopping >= target)
+}
+
+// distanceTraveled returns the planar distance in meters between two odometry poses
+func distanceTraveled(start, current *nav_msgs.Odometry) float64 {
+	dx := current.Pose.Pose.Position.X - start.Pose.Pose.Position.X
+	dy := current.Pose.Pose.Position.Y - start.Pose.Pose.Position.Y
+	return math.Hypot(dx, dy)
+}
+
+// currentYaw returns the heading in radians from the configured imu or, if none, from odometry
+func (r *RosBase) currentYaw(ctx context.Context) (float64, error) {
+	r.mu.Lock()
+	imu := r.imu
+	r.mu.Unlock()
+
+	if imu != nil {
+		o, err := imu.Orientation(ctx, nil)
+		if err != nil {
+			return 0, err
+		}
+		return o.EulerAngles().Yaw, nil
+	}
+
+	msg, err := r.waitForOdometry(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return yawFromQuaternion(msg.Pose.Pose.Orientation), nil
+}
+
+// yawFromQuaternion returns the rotation about z in radians
+func yawFromQuaternion(q geometry_msgs.Quaternion) float64 {
+	o := &spatialmath.Quaternion{Real: q.W, Imag: q.X, Jmag: q.Y, Kmag: q.Z}
+	return o.EulerAngles().Yaw
+}
+
+// yawDelta returns the smallest signed angle from prev to current, handling wrap-around at +/- pi
+func yawDelta(prev, current float64) float64 {
+	d := math.Mod(current-prev, 2*math.Pi)
+	if d > math.Pi {
+		d -= 2 * math.Pi
+	} else if d < -math.Pi {
+		d += 2 * math.Pi
+	}
+	return d
+}
+
+// measuredMoving reports if the odometry twist is over the moving thresholds, the second value is
+// false when there is no recent odometry, caller must hold r.mu
+func (r *RosBase) measuredMoving(now time.Time) (bool, bool) {
+	if r.odomMsg == nil || now.Sub(r.odomReceived) > odomStaleTimeout {
+		return false, false
+	}
+	twist := r.odomMsg.Twist.Twist
+	linear := math.Sqrt(twist.Linear.X*twist.Linear.X + twist.Linear.Y*twist.Linear.Y + twist.Linear.Z*twist.Linear.Z)
+	angular := math.Abs(twist.Angular.Z)
+	return linear > r.movingLinear || angular > r.movingAngular, true
+}
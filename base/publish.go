@@ -0,0 +1,89 @@
+package base
+
+import (
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+)
+
+// publish modes for the twist thread
+const (
+	// PublishContinuous writes the current twist every time_rate_ms
+	PublishContinuous = "continuous"
+	// PublishOnChange writes the twist only when a command changes it
+	PublishOnChange = "on_change"
+	// PublishUntilStopped writes while moving, then a few zero twists and goes quiet
+	PublishUntilStopped = "until_stopped"
+)
+
+// stopRepeatCount is the number of zero twists sent after a stop in until_stopped mode
+const stopRepeatCount = 3
+
+// setTwist replaces the commanded twist, caller must hold r.mu
+func (r *RosBase) setTwist(twist *geometry_msgs.Twist, moving bool) {
+	limited, clamped := r.limits.clamp(twist)
+	if clamped {
+		r.logger.Warnf("twist linear %+v angular %+v clamped to linear %+v angular %+v",
+			twist.Linear, twist.Angular, limited.Linear, limited.Angular)
+	}
+	r.twistMsg = limited
+	r.moving = moving
+	r.twistChanged = true
+	r.lastCommand = time.Time{}
+	if !moving {
+		r.stopRepeats = stopRepeatCount
+	}
+}
+
+// setCommandedTwist replaces the twist from an open-loop command watched by
+// command_timeout_ms, caller must hold r.mu
+func (r *RosBase) setCommandedTwist(twist *geometry_msgs.Twist) {
+	r.setTwist(twist, true)
+	r.lastCommand = time.Now()
+}
+
+// checkCommandTimeout stops the base when no SetPower/SetVelocity arrived within
+// command_timeout_ms, caller must hold r.mu
+func (r *RosBase) checkCommandTimeout(now time.Time) {
+	if r.commandTimeout <= 0 || r.lastCommand.IsZero() {
+		return
+	}
+	if now.Sub(r.lastCommand) > r.commandTimeout {
+		r.logger.Warnf("no command received in %s, stopping base", r.commandTimeout)
+		r.setTwist(&geometry_msgs.Twist{}, false)
+	}
+}
+
+// publishTick runs the watchdog and ramps the output twist towards the commanded
+// twist, returning the twist to write and if it should be written, caller must hold r.mu
+func (r *RosBase) publishTick(now time.Time) (*geometry_msgs.Twist, bool) {
+	r.checkCommandTimeout(now)
+	next := r.limits.ramp(r.outputTwist, r.twistMsg, r.timeRate.Seconds())
+	if *next != *r.outputTwist {
+		r.twistChanged = true
+	}
+	r.outputTwist = next
+	return r.outputTwist, r.shouldPublish()
+}
+
+// shouldPublish reports if the twist should be written on this tick, caller must hold r.mu
+func (r *RosBase) shouldPublish() bool {
+	switch r.publishMode {
+	case PublishOnChange:
+		changed := r.twistChanged
+		r.twistChanged = false
+		return changed
+	case PublishUntilStopped:
+		// keep publishing while ramping down after a stop
+		if r.moving || !isZeroTwist(r.outputTwist) {
+			return true
+		}
+		if r.stopRepeats > 0 {
+			r.stopRepeats--
+			return true
+		}
+		return false
+	default:
+		return true
+	}
+}
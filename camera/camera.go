@@ -10,9 +10,11 @@ import (
 	viamcamera "go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
 	"image"
 	"strings"
 	"sync"
+	"time"
 )
 
 var RosCameraModel = resource.NewModel("brokenrobotz", "ros", "camera")
@@ -39,14 +41,27 @@ func NewRosMediaSource(
 type RosMediaSource struct {
 	resource.Named
 
-	ctx        context.Context
-	logger     logging.Logger
-	mu         sync.Mutex
-	img        image.Image
-	primaryUri string
-	topic      string
-	node       *goroslib.Node
-	subscriber *goroslib.Subscriber
+	ctx            context.Context
+	logger         logging.Logger
+	mu             sync.Mutex
+	current        *frame
+	maxFrameAge    time.Duration // 0 never expires frames
+	limiter        *rateLimiter
+	targetWidth    int // 0 keeps the image width
+	targetHeight   int // 0 keeps the image height
+	primaryUri     string
+	topic          string
+	compressed     bool
+	streams        []*namedStream
+	syncTolerance  time.Duration
+	syncLimiter    *rateLimiter // max_fps of the synchronized sets, by header stamp
+	lastSet        []*frame     // last synchronized set returned by Images
+	infoTopic      string
+	node           *goroslib.Node
+	subscriber     *goroslib.Subscriber
+	infoSubscriber *goroslib.Subscriber
+	intrinsics     *transform.PinholeCameraIntrinsics
+	distortion     *transform.BrownConrady
 }
 
 func (rs *RosMediaSource) Reconfigure(
@@ -59,17 +74,35 @@ func (rs *RosMediaSource) Reconfigure(
 	defer rs.mu.Unlock()
 	rs.primaryUri = conf.Attributes.String("primary_uri")
 	rs.topic = conf.Attributes.String("topic")
+	rs.compressed = conf.Attributes.Bool("compressed", compressedTopic(rs.topic))
+	rs.infoTopic = conf.Attributes.String("camera_info_topic")
+	rs.maxFrameAge = time.Duration(conf.Attributes.Int("max_frame_age_ms", 0)) * time.Millisecond
+	rs.syncTolerance = time.Duration(conf.Attributes.Int("sync_tolerance_ms", defaultSyncToleranceMs)) * time.Millisecond
+	maxFps := conf.Attributes.Float64("max_fps", 0)
+	rs.limiter = newRateLimiter(maxFps)
+	rs.targetWidth = conf.Attributes.Int("target_width", 0)
+	rs.targetHeight = conf.Attributes.Int("target_height", 0)
 
 	if len(strings.TrimSpace(rs.primaryUri)) == 0 {
 		return errors.New("ROS primary uri must be set to hostname:port")
 	}
 
-	if len(strings.TrimSpace(rs.topic)) == 0 {
+	cfg, err := resource.NativeConfig[*RosMediaSourceConfig](conf)
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(rs.topic)) == 0 && len(cfg.Topics) == 0 {
 		return errors.New("ROS topic must be set to valid camera topic")
 	}
 
-	if rs.subscriber != nil {
-		rs.subscriber.Close()
+	rs.closeSubscribers()
+	infoFor := rs.topic
+	if len(strings.TrimSpace(infoFor)) == 0 {
+		infoFor = cfg.Topics[0].Topic
+	}
+	if len(strings.TrimSpace(rs.infoTopic)) == 0 {
+		rs.infoTopic = defaultCameraInfoTopic(infoFor)
 	}
 
 	rs.node, err = viamrosnode.GetInstance(rs.primaryUri)
@@ -77,10 +110,44 @@ func (rs *RosMediaSource) Reconfigure(
 		return err
 	}
 
-	rs.subscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+	rs.current = nil
+	if len(strings.TrimSpace(rs.topic)) > 0 {
+		var callback interface{} = rs.updateImageFromRosMsg
+		if rs.compressed {
+			callback = rs.updateImageFromCompressedMsg
+		}
+		rs.subscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+			Node:     rs.node,
+			Topic:    rs.topic,
+			Callback: callback,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	rs.streams = nil
+	rs.syncLimiter = newRateLimiter(maxFps)
+	rs.lastSet = nil
+	for _, t := range cfg.Topics {
+		stream := newNamedStream(t)
+		stream.subscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+			Node:     rs.node,
+			Topic:    stream.topic,
+			Callback: rs.streamCallback(stream),
+		})
+		if err != nil {
+			return err
+		}
+		rs.streams = append(rs.streams, stream)
+	}
+
+	rs.intrinsics = nil
+	rs.distortion = nil
+	rs.infoSubscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
 		Node:     rs.node,
-		Topic:    rs.topic,
-		Callback: rs.updateImageFromRosMsg,
+		Topic:    rs.infoTopic,
+		Callback: rs.processCameraInfo,
 	})
 	if err != nil {
 		return err
@@ -89,41 +156,109 @@ func (rs *RosMediaSource) Reconfigure(
 	return nil
 }
 
+// closeSubscribers closes all subscribers, caller must hold rs.mu
+func (rs *RosMediaSource) closeSubscribers() {
+	subscribers := []*goroslib.Subscriber{rs.subscriber, rs.infoSubscriber}
+	for _, stream := range rs.streams {
+		subscribers = append(subscribers, stream.subscriber)
+	}
+	for _, s := range subscribers {
+		if s != nil {
+			s.Close()
+		}
+	}
+	rs.subscriber = nil
+	rs.infoSubscriber = nil
+}
+
+// checkAge errors for frames older than max_frame_age_ms, the age uses the local receive
+// time so clock differences with the ROS machine don't matter, caller must hold rs.mu
+func (rs *RosMediaSource) checkAge(f *frame) error {
+	if age := time.Since(f.received); rs.maxFrameAge > 0 && age > rs.maxFrameAge {
+		return fmt.Errorf("last image is %s old", age.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// frame returns the last frame of topic, or of the first named stream when there is no topic
+func (rs *RosMediaSource) frame() (image.Image, time.Time, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	f := rs.current
+	if rs.subscriber == nil && len(rs.streams) > 0 {
+		f = rs.streams[0].latest()
+	}
+	if f == nil {
+		return nil, time.Time{}, fmt.Errorf("image is not ready")
+	}
+	if err := rs.checkAge(f); err != nil {
+		return nil, time.Time{}, err
+	}
+	img, err := f.image(rs.targetWidth, rs.targetHeight)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return img, f.stamp, nil
+}
+
 func (rs *RosMediaSource) Read(_ context.Context) (image.Image, func(), error) {
-	if rs.img != nil {
-		return rs.img, func() {}, nil
-	} else {
-		return nil, nil, fmt.Errorf("image is not ready")
+	img, _, err := rs.frame()
+	if err != nil {
+		return nil, nil, err
 	}
+	return img, func() {}, nil
+}
+
+// Images returns the frame with its header stamp as the capture time, or one time
+// synchronized frame of every named stream when topics are configured
+func (rs *RosMediaSource) Images(_ context.Context) ([]viamcamera.NamedImage, resource.ResponseMetadata, error) {
+	rs.mu.Lock()
+	synced := len(rs.streams) > 0
+	rs.mu.Unlock()
+	if synced {
+		return rs.syncedImages()
+	}
+	img, stamp, err := rs.frame()
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	return []viamcamera.NamedImage{{Image: img, SourceName: ""}}, resource.ResponseMetadata{CapturedAt: stamp}, nil
 }
 
 func (rs *RosMediaSource) Close(_ context.Context) error {
-	rs.subscriber.Close()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.closeSubscribers()
 	return nil
 }
 
 func (rs *RosMediaSource) updateImageFromRosMsg(msg *sensor_msgs.Image) {
-
-	if msg == nil || len(msg.Data) == 0 {
-		rs.logger.Warn("ROS image data not ready")
+	f := rs.imageFrame(msg)
+	if f == nil {
 		return
 	}
 	rs.mu.Lock()
-	newImage := convertImage(msg)
-	rs.mu.Unlock()
+	defer rs.mu.Unlock()
+	if rs.limiter.allow(f.received) {
+		rs.current = f
+	}
+}
 
-	rs.img = newImage
+// imageFrame wraps the raw image for lazy conversion, nil for empty messages
+func (rs *RosMediaSource) imageFrame(msg *sensor_msgs.Image) *frame {
+	if msg == nil || len(msg.Data) == 0 {
+		rs.logger.Warn("ROS image data not ready")
+		return nil
+	}
+	return newFrame(nil, msg, msg.Header.Stamp)
 }
 
-func convertImage(msg *sensor_msgs.Image) image.Image {
-	ri := RosImage{height: int(msg.Height), width: int(msg.Width), step: int(msg.Step), data: msg.Data}
-	rgbaImage := image.NewRGBA(ri.Bounds())
-	for x := 0; x < int(msg.Height); x++ {
-		for y := 0; y < int(msg.Width); y++ {
-			rgbaImage.Set(x, y, ri.At(x, y))
-		}
+func convertImage(msg *sensor_msgs.Image) (image.Image, error) {
+	ri, err := newRosImage(msg)
+	if err != nil {
+		return nil, err
 	}
-	return rgbaImage
+	return ri.toImage(), nil
 }
 
 func init() {
@@ -159,5 +294,8 @@ func NewRosCamera(
 		logger.Error("problem created new video source")
 		return nil, err
 	}
-	return viamcamera.FromVideoSource(conf.ResourceName(), videoSrc, logger), nil
+	return &rosCamera{
+		Camera: viamcamera.FromVideoSource(conf.ResourceName(), videoSrc, logger),
+		source: rosVideoSrc,
+	}, nil
 }
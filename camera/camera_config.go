@@ -6,6 +6,30 @@ type RosMediaSourceConfig struct {
 	NodeName   string `json:"node_name"`
 	PrimaryUri string `json:"primary_uri"`
 	Topic      string `json:"topic"`
+	// subscribe to sensor_msgs/CompressedImage, defaults to true for topics ending in /compressed
+	Compressed *bool `json:"compressed,omitempty"`
+	// sensor_msgs/CameraInfo topic, defaults to camera_info next to the image topic
+	CameraInfoTopic string `json:"camera_info_topic"`
+	// Read errors when the last frame is older than this, 0 disables the check
+	MaxFrameAge int `json:"max_frame_age_ms"`
+	// named image topics returned together by Images, topic is optional when these are set
+	Topics []NamedTopicConfig `json:"topics,omitempty"`
+	// largest stamp difference between the frames of one Images call, defaults to 50
+	SyncTolerance *int `json:"sync_tolerance_ms,omitempty"`
+	// messages arriving faster are dropped before conversion, for topics it limits the
+	// synchronized sets by header stamp, 0 keeps every message
+	MaxFps float64 `json:"max_fps,omitempty"`
+	// resize images, when only one is set the other keeps the aspect ratio
+	TargetWidth  int `json:"target_width,omitempty"`
+	TargetHeight int `json:"target_height,omitempty"`
+}
+
+// NamedTopicConfig is one image topic of a synchronized set, i.e. the left camera of a stereo pair
+type NamedTopicConfig struct {
+	Name  string `json:"name"`
+	Topic string `json:"topic"`
+	// defaults to true for topics ending in /compressed
+	Compressed *bool `json:"compressed,omitempty"`
 }
 
 func (cfg *RosMediaSourceConfig) Validate(path string) ([]string, error) {
@@ -14,10 +38,37 @@ func (cfg *RosMediaSourceConfig) Validate(path string) ([]string, error) {
 		return nil, fmt.Errorf(`expected "PrimaryUri" attribute for sensor %q`, path)
 	}
 
-	if cfg.Topic == "" {
+	if cfg.Topic == "" && len(cfg.Topics) == 0 {
 		return nil, fmt.Errorf(`expected "RosTopic" attribute for sensor %q`, path)
 	}
 
+	names := map[string]bool{}
+	for i, t := range cfg.Topics {
+		if t.Name == "" || t.Topic == "" {
+			return nil, fmt.Errorf(`"topics" entry %d needs a "name" and a "topic" for sensor %q`, i, path)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf(`"topics" name %q is used twice for sensor %q`, t.Name, path)
+		}
+		names[t.Name] = true
+	}
+
+	if cfg.SyncTolerance != nil && *cfg.SyncTolerance < 0 {
+		return nil, fmt.Errorf(`"sync_tolerance_ms" can not be negative for sensor %q`, path)
+	}
+
+	if cfg.MaxFps < 0 {
+		return nil, fmt.Errorf(`"max_fps" can not be negative for sensor %q`, path)
+	}
+
+	if cfg.TargetWidth < 0 || cfg.TargetHeight < 0 {
+		return nil, fmt.Errorf(`"target_width" and "target_height" can not be negative for sensor %q`, path)
+	}
+
+	if cfg.MaxFrameAge < 0 {
+		return nil, fmt.Errorf(`"max_frame_age_ms" can not be negative for sensor %q`, path)
+	}
+
 	return nil, nil
 }
 
@@ -0,0 +1,133 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	viamcamera "go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+/*
+ * sensor_msgs/CameraInfo
+ * K is the row major 3x3 intrinsic matrix [fx 0 cx; 0 fy cy; 0 0 1].
+ * plumb_bob D holds k1, k2, p1, p2, k3, which is the viam Brown-Conrady model.
+ * rational_polynomial D adds k4, k5, k6 in the denominator, it is only used
+ * when those are zero and it reduces to plumb_bob.
+ * An uncalibrated camera publishes a zero K and gets no intrinsics.
+ */
+
+const (
+	distortionPlumbBob           = "plumb_bob"
+	distortionRationalPolynomial = "rational_polynomial"
+)
+
+// defaultCameraInfoTopic returns the camera_info topic next to the image topic,
+// /camera/image_raw and /camera/image_raw/compressed both use /camera/camera_info
+func defaultCameraInfoTopic(topic string) string {
+	topic = strings.TrimSuffix(topic, "/compressed")
+	return path.Join(path.Dir(topic), "camera_info")
+}
+
+// cameraInfoIntrinsics converts K, nil when the camera is not calibrated
+func cameraInfoIntrinsics(msg *sensor_msgs.CameraInfo) *transform.PinholeCameraIntrinsics {
+	k := msg.K
+	if k[0] == 0 || k[4] == 0 {
+		return nil
+	}
+	return &transform.PinholeCameraIntrinsics{
+		Width:  int(msg.Width),
+		Height: int(msg.Height),
+		Fx:     k[0],
+		Fy:     k[4],
+		Ppx:    k[2],
+		Ppy:    k[5],
+	}
+}
+
+// cameraInfoDistortion converts D, nil for other distortion models, a rational_polynomial
+// that uses k4-k6 or no distortion
+func cameraInfoDistortion(msg *sensor_msgs.CameraInfo) *transform.BrownConrady {
+	switch msg.DistortionModel {
+	case distortionPlumbBob:
+	case distortionRationalPolynomial:
+		for _, k := range msg.D[min(len(msg.D), 5):] {
+			if k != 0 {
+				return nil
+			}
+		}
+	default:
+		return nil
+	}
+	var d [5]float64
+	copy(d[:], msg.D)
+	if d == [5]float64{} {
+		return nil
+	}
+	return &transform.BrownConrady{
+		RadialK1:     d[0],
+		RadialK2:     d[1],
+		TangentialP1: d[2],
+		TangentialP2: d[3],
+		RadialK3:     d[4],
+	}
+}
+
+func (rs *RosMediaSource) processCameraInfo(msg *sensor_msgs.CameraInfo) {
+	intrinsics := cameraInfoIntrinsics(msg)
+	distortion := cameraInfoDistortion(msg)
+	if msg.DistortionModel != "" && distortion == nil && len(msg.D) > 0 {
+		rs.logger.Debugf("ignoring unsupported distortion model %q", msg.DistortionModel)
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if intrinsics != nil {
+		intrinsics = scaleIntrinsics(intrinsics, rs.targetWidth, rs.targetHeight)
+	}
+	rs.intrinsics = intrinsics
+	rs.distortion = distortion
+}
+
+// cameraModel returns the last intrinsics and distortion received
+func (rs *RosMediaSource) cameraModel() (*transform.PinholeCameraIntrinsics, *transform.BrownConrady) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.intrinsics, rs.distortion
+}
+
+// cameraModelSource is a camera reader that receives CameraInfo messages
+type cameraModelSource interface {
+	cameraModel() (*transform.PinholeCameraIntrinsics, *transform.BrownConrady)
+}
+
+// rosCamera reports the camera info received after the video source was created
+type rosCamera struct {
+	viamcamera.Camera
+	source cameraModelSource
+}
+
+func (c *rosCamera) Properties(ctx context.Context) (viamcamera.Properties, error) {
+	props, err := c.Camera.Properties(ctx)
+	if err != nil {
+		return props, err
+	}
+	intrinsics, distortion := c.source.cameraModel()
+	if intrinsics != nil {
+		props.IntrinsicParams = intrinsics
+	}
+	if distortion != nil {
+		props.DistortionParams = distortion
+	}
+	return props, nil
+}
+
+func (c *rosCamera) Projector(_ context.Context) (transform.Projector, error) {
+	intrinsics, _ := c.source.cameraModel()
+	if intrinsics == nil {
+		return nil, errors.New("camera info is not ready")
+	}
+	return intrinsics, nil
+}
@@ -1,8 +1,22 @@
 package camera
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	viamcamera "go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/rdk/utils"
 	"go.viam.com/test"
 )
 
@@ -10,3 +24,368 @@ func TestCameraImage(t *testing.T) {
 	placeHolder := true
 	test.ShouldBeTrue(placeHolder)
 }
+
+func rgba(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+func TestImageEncodings(t *testing.T) {
+	newImage := func(encoding string, width, height, step uint32, data []byte) *RosImage {
+		ri, err := newRosImage(&sensor_msgs.Image{
+			Width: width, Height: height, Step: step, Encoding: encoding, Data: data,
+		})
+		test.That(t, err, test.ShouldBeNil)
+		return ri
+	}
+
+	// one row of two pixels with a padded step
+	ri := newImage(EncodingRGB8, 2, 1, 8, []byte{1, 2, 3, 4, 5, 6, 0, 0})
+	test.That(t, rgba(ri.decode(0, 1)), test.ShouldResemble, color.RGBA{R: 4, G: 5, B: 6, A: 255})
+	ri = newImage(EncodingBGR8, 1, 1, 3, []byte{1, 2, 3})
+	test.That(t, rgba(ri.decode(0, 0)), test.ShouldResemble, color.RGBA{R: 3, G: 2, B: 1, A: 255})
+	ri = newImage(EncodingBGRA8, 1, 1, 4, []byte{1, 2, 3, 255})
+	test.That(t, rgba(ri.decode(0, 0)), test.ShouldResemble, color.RGBA{R: 3, G: 2, B: 1, A: 255})
+
+	// mono8 is one byte per pixel and must not read past the data
+	ri = newImage(EncodingMono8, 2, 2, 2, []byte{1, 2, 3, 4})
+	test.That(t, ri.decode(1, 1), test.ShouldResemble, color.Gray{Y: 4})
+
+	// 16 bit values honor the endianness
+	ri = newImage(EncodingMono16, 1, 1, 2, []byte{0x01, 0x02})
+	test.That(t, ri.decode(0, 0), test.ShouldResemble, color.Gray16{Y: 0x0201})
+	big, err := newRosImage(&sensor_msgs.Image{
+		Width: 1, Height: 1, Step: 2, Encoding: Encoding16UC1, IsBigendian: 1, Data: []byte{0x01, 0x02},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, big.decode(0, 0), test.ShouldResemble, color.Gray16{Y: 0x0102})
+
+	// float depth in meters becomes millimeters
+	depth := make([]byte, 8)
+	binary.LittleEndian.PutUint32(depth, math.Float32bits(1.5))
+	binary.LittleEndian.PutUint32(depth[4:], math.Float32bits(float32(math.NaN())))
+	ri = newImage(Encoding32FC1, 2, 1, 8, depth)
+	test.That(t, ri.decode(0, 0), test.ShouldResemble, color.Gray16{Y: 1500})
+	test.That(t, ri.decode(0, 1), test.ShouldResemble, color.Gray16{Y: 0})
+
+	// yuv422 is u y0 v y1, gray when u and v are centered
+	ri = newImage(EncodingYUV422, 2, 1, 4, []byte{128, 10, 128, 200})
+	test.That(t, rgba(ri.decode(0, 0)), test.ShouldResemble, color.RGBA{R: 10, G: 10, B: 10, A: 255})
+	test.That(t, rgba(ri.decode(0, 1)), test.ShouldResemble, color.RGBA{R: 200, G: 200, B: 200, A: 255})
+
+	// every pixel of a bayer block gets the block color
+	ri = newImage("bayer_rggb8", 2, 2, 2, []byte{100, 20, 40, 200})
+	for _, p := range [][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}} {
+		test.That(t, rgba(ri.decode(p[0], p[1])), test.ShouldResemble, color.RGBA{R: 100, G: 30, B: 200, A: 255})
+	}
+	ri = newImage("bayer_bggr16", 2, 2, 4, []byte{0, 100, 0, 20, 0, 40, 0, 200})
+	test.That(t, rgba(ri.decode(1, 1)), test.ShouldResemble, color.RGBA{R: 200, G: 30, B: 100, A: 255})
+}
+
+func TestImageEncodingErrors(t *testing.T) {
+	_, err := newRosImage(&sensor_msgs.Image{Width: 1, Height: 1, Step: 3, Encoding: "8UC3", Data: []byte{1, 2, 3}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unsupported image encoding")
+
+	// too little data for the image size
+	_, err = newRosImage(&sensor_msgs.Image{Width: 2, Height: 2, Step: 6, Encoding: EncodingRGB8, Data: []byte{1, 2, 3}})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = convertImage(&sensor_msgs.Image{Width: 2, Height: 1, Step: 2, Encoding: EncodingRGB8, Data: []byte{1, 2}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestCompressedImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	var buf bytes.Buffer
+	test.That(t, jpeg.Encode(&buf, src, nil), test.ShouldBeNil)
+
+	mimeType, err := compressedMimeType("bgr8; jpeg compressed bgr8", buf.Bytes())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, mimeType, test.ShouldEqual, utils.MimeTypeJPEG)
+	mimeType, err = compressedMimeType("", buf.Bytes())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, mimeType, test.ShouldEqual, utils.MimeTypeJPEG)
+	mimeType, err = compressedMimeType("png", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, mimeType, test.ShouldEqual, utils.MimeTypePNG)
+	_, err = compressedMimeType("16UC1; compressedDepth png", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	rs := &RosMediaSource{logger: logging.NewTestLogger(t)}
+	rs.updateImageFromCompressedMsg(&sensor_msgs.CompressedImage{Format: "jpeg", Data: buf.Bytes()})
+	img, _, err := rs.Read(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+
+	// jpeg requests get the original bytes, other formats are decoded
+	out, err := rimage.EncodeImage(context.Background(), img, utils.MimeTypeJPEG)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out, test.ShouldResemble, buf.Bytes())
+	test.That(t, img.Bounds(), test.ShouldResemble, src.Bounds())
+}
+
+func TestCameraInfo(t *testing.T) {
+	test.That(t, defaultCameraInfoTopic("/camera/image_raw"), test.ShouldEqual, "/camera/camera_info")
+	test.That(t, defaultCameraInfoTopic("/camera/color/image_raw/compressed"), test.ShouldEqual, "/camera/color/camera_info")
+
+	msg := &sensor_msgs.CameraInfo{
+		Width:           640,
+		Height:          480,
+		DistortionModel: "plumb_bob",
+		D:               []float64{0.1, -0.2, 0.001, 0.002, 0.05},
+		K:               [9]float64{600, 0, 320, 0, 610, 240, 0, 0, 1},
+	}
+	intrinsics := cameraInfoIntrinsics(msg)
+	test.That(t, intrinsics, test.ShouldResemble, &transform.PinholeCameraIntrinsics{
+		Width: 640, Height: 480, Fx: 600, Fy: 610, Ppx: 320, Ppy: 240,
+	})
+	test.That(t, cameraInfoDistortion(msg), test.ShouldResemble, &transform.BrownConrady{
+		RadialK1: 0.1, RadialK2: -0.2, TangentialP1: 0.001, TangentialP2: 0.002, RadialK3: 0.05,
+	})
+
+	// uncalibrated and unsupported models are left out
+	test.That(t, cameraInfoIntrinsics(&sensor_msgs.CameraInfo{}), test.ShouldBeNil)
+	test.That(t, cameraInfoDistortion(&sensor_msgs.CameraInfo{DistortionModel: "equidistant", D: []float64{1}}), test.ShouldBeNil)
+
+	// rational_polynomial is only brown-conrady when k4-k6 are zero
+	rational := &sensor_msgs.CameraInfo{DistortionModel: "rational_polynomial", D: []float64{0.1, 0, 0, 0, 0, 0, 0, 0}}
+	test.That(t, cameraInfoDistortion(rational), test.ShouldResemble, &transform.BrownConrady{RadialK1: 0.1})
+	rational.D[5] = 0.2
+	test.That(t, cameraInfoDistortion(rational), test.ShouldBeNil)
+
+	// properties follow the camera info received after the camera was created
+	logger := logging.NewTestLogger(t)
+	rs := &RosMediaSource{logger: logger}
+	camModel := viamcamera.NewPinholeModelWithBrownConradyDistortion(nil, nil)
+	src, err := viamcamera.NewVideoSourceFromReader(context.Background(), rs, &camModel, viamcamera.ColorStream)
+	test.That(t, err, test.ShouldBeNil)
+	cam := &rosCamera{Camera: viamcamera.FromVideoSource(viamcamera.Named("test"), src, logger), source: rs}
+
+	props, err := cam.Properties(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.IntrinsicParams, test.ShouldBeNil)
+
+	rs.processCameraInfo(msg)
+	props, err = cam.Properties(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.IntrinsicParams, test.ShouldResemble, intrinsics)
+	test.That(t, props.DistortionParams, test.ShouldNotBeNil)
+}
+
+func TestConvertImageOrientation(t *testing.T) {
+	// 3 wide and 2 tall with 2 bytes of padding per row
+	msg := &sensor_msgs.Image{
+		Width: 3, Height: 2, Step: 11, Encoding: EncodingBGR8,
+		Data: []byte{
+			1, 0, 0, 2, 0, 0, 3, 0, 0, 9, 9,
+			4, 0, 0, 5, 0, 0, 6, 0, 0, 9, 9,
+		},
+	}
+	img, err := convertImage(msg)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds(), test.ShouldResemble, image.Rect(0, 0, 3, 2))
+	test.That(t, rgba(img.At(2, 0)), test.ShouldResemble, color.RGBA{B: 3, A: 255})
+	test.That(t, rgba(img.At(0, 1)), test.ShouldResemble, color.RGBA{B: 4, A: 255})
+
+	// the row copies must agree with the per pixel decoding
+	ri, err := newRosImage(msg)
+	test.That(t, err, test.ShouldBeNil)
+	for _, encoding := range []string{EncodingMono8, EncodingMono16, EncodingRGB8, EncodingRGBA8, EncodingBGRA8} {
+		bpp, err := bytesPerPixel(encoding)
+		test.That(t, err, test.ShouldBeNil)
+		data := make([]byte, 2*(3*bpp+1))
+		for i := range data {
+			data[i] = byte(i*7 + 1)
+		}
+		ri = &RosImage{width: 3, height: 2, step: 3*bpp + 1, bytesPerPixel: bpp, encoding: encoding, data: data}
+		img := ri.toImage()
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 3; x++ {
+				test.That(t, color.RGBA64Model.Convert(img.At(x, y)), test.ShouldResemble,
+					color.RGBA64Model.Convert(ri.At(x, y)))
+			}
+		}
+	}
+}
+
+func TestLazyConversion(t *testing.T) {
+	rs := &RosMediaSource{logger: logging.NewTestLogger(t)}
+	_, _, err := rs.Read(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// nothing is converted until the image is read
+	rs.updateImageFromRosMsg(&sensor_msgs.Image{Width: 1, Height: 1, Step: 1, Encoding: "8UC1", Data: []byte{1}})
+	test.That(t, rs.current.img, test.ShouldBeNil)
+	_, _, err = rs.Read(context.Background())
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unsupported image encoding")
+
+	rs.updateImageFromRosMsg(&sensor_msgs.Image{Width: 1, Height: 1, Step: 1, Encoding: EncodingMono8, Data: []byte{7}})
+	img, _, err := rs.Read(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.At(0, 0), test.ShouldResemble, color.Gray{Y: 7})
+	again, _, err := rs.Read(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, again, test.ShouldEqual, img)
+}
+
+func TestFrameStamps(t *testing.T) {
+	rs := &RosMediaSource{logger: logging.NewTestLogger(t)}
+	stamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	msg := &sensor_msgs.Image{Width: 1, Height: 1, Step: 1, Encoding: EncodingMono8, Data: []byte{7}}
+	msg.Header.Stamp = stamp
+	rs.updateImageFromRosMsg(msg)
+
+	images, meta, err := rs.Images(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 1)
+	test.That(t, meta.CapturedAt, test.ShouldEqual, stamp)
+
+	// an empty stamp falls back to the receive time
+	msg.Header.Stamp = time.Time{}
+	rs.updateImageFromRosMsg(msg)
+	_, meta, err = rs.Images(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, time.Since(meta.CapturedAt), test.ShouldBeLessThan, time.Second)
+
+	// stale frames are an error
+	rs.maxFrameAge = 50 * time.Millisecond
+	_, _, err = rs.Read(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	rs.mu.Lock()
+	rs.current.received = time.Now().Add(-time.Second)
+	rs.mu.Unlock()
+	_, _, err = rs.Read(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "old")
+}
+
+func TestSyncedImages(t *testing.T) {
+	rs := &RosMediaSource{logger: logging.NewTestLogger(t), syncTolerance: 10 * time.Millisecond}
+	left := newNamedStream(NamedTopicConfig{Name: "left", Topic: "/stereo/left/image_raw"})
+	right := newNamedStream(NamedTopicConfig{Name: "right", Topic: "/stereo/right/image_raw/compressed"})
+	test.That(t, right.compressed, test.ShouldBeTrue)
+	right.compressed = false
+	rs.streams = []*namedStream{left, right}
+	onLeft := rs.streamCallback(left).(func(*sensor_msgs.Image))
+	onRight := rs.streamCallback(right).(func(*sensor_msgs.Image))
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	publish := func(callback func(*sensor_msgs.Image), ms int, value byte) {
+		msg := &sensor_msgs.Image{Width: 1, Height: 1, Step: 1, Encoding: EncodingMono8, Data: []byte{value}}
+		msg.Header.Stamp = base.Add(time.Duration(ms) * time.Millisecond)
+		callback(msg)
+	}
+
+	publish(onLeft, 0, 1)
+	_, _, err := rs.Images(context.Background())
+	test.That(t, err.Error(), test.ShouldContainSubstring, "right image is not ready")
+
+	// the newest left frame has no match yet, the previous pair is returned
+	publish(onRight, 3, 2)
+	publish(onLeft, 100, 3)
+	images, meta, err := rs.Images(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 2)
+	test.That(t, images[0].SourceName, test.ShouldEqual, "left")
+	test.That(t, images[0].Image.At(0, 0), test.ShouldResemble, color.Gray{Y: 1})
+	test.That(t, images[1].SourceName, test.ShouldEqual, "right")
+	test.That(t, images[1].Image.At(0, 0), test.ShouldResemble, color.Gray{Y: 2})
+	test.That(t, meta.CapturedAt, test.ShouldEqual, base)
+
+	publish(onRight, 95, 4)
+	images, meta, err = rs.Images(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, images[0].Image.At(0, 0), test.ShouldResemble, color.Gray{Y: 3})
+	test.That(t, images[1].Image.At(0, 0), test.ShouldResemble, color.Gray{Y: 4})
+	test.That(t, meta.CapturedAt, test.ShouldEqual, base.Add(100*time.Millisecond))
+
+	// Read returns the first stream when there is no single topic
+	img, _, err := rs.Read(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.At(0, 0), test.ShouldResemble, color.Gray{Y: 3})
+
+	_, err = syncFrames([]*namedStream{
+		{name: "a", frames: []*frame{{stamp: base}}},
+		{name: "b", frames: []*frame{{stamp: base.Add(time.Second)}}},
+	}, 10*time.Millisecond)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(15)
+	kept := 0
+	// 30fps with a little jitter keeps every other frame
+	for i := 0; i < 30; i++ {
+		jitter := time.Duration(i%3-1) * time.Millisecond
+		if l.allow(start.Add(time.Duration(i)*time.Second/30 + jitter)) {
+			kept++
+		}
+	}
+	test.That(t, kept, test.ShouldEqual, 15)
+
+	unlimited := newRateLimiter(0)
+	test.That(t, unlimited.allow(start), test.ShouldBeTrue)
+	test.That(t, unlimited.allow(start), test.ShouldBeTrue)
+}
+
+func TestResize(t *testing.T) {
+	w, h := targetSize(640, 480, 320, 0)
+	test.That(t, []int{w, h}, test.ShouldResemble, []int{320, 240})
+	w, h = targetSize(640, 480, 0, 120)
+	test.That(t, []int{w, h}, test.ShouldResemble, []int{160, 120})
+	w, h = targetSize(640, 480, 0, 0)
+	test.That(t, []int{w, h}, test.ShouldResemble, []int{640, 480})
+
+	depth := image.NewGray16(image.Rect(0, 0, 4, 4))
+	depth.SetGray16(0, 0, color.Gray16{Y: 1000})
+	small := resizeImage(depth, 2, 2)
+	test.That(t, small.Bounds(), test.ShouldResemble, image.Rect(0, 0, 2, 2))
+	test.That(t, small.(*image.Gray16).Gray16At(0, 0).Y, test.ShouldBeIn, []uint16{0, 1000})
+
+	in := &transform.PinholeCameraIntrinsics{Width: 640, Height: 480, Fx: 500, Fy: 500, Ppx: 320, Ppy: 240}
+	out := scaleIntrinsics(in, 320, 0)
+	test.That(t, *out, test.ShouldResemble, transform.PinholeCameraIntrinsics{
+		Width: 320, Height: 240, Fx: 250, Fy: 250, Ppx: 160, Ppy: 120,
+	})
+
+	// frames are resized once on read
+	rs := &RosMediaSource{logger: logging.NewTestLogger(t), targetWidth: 1}
+	rs.updateImageFromRosMsg(&sensor_msgs.Image{Width: 2, Height: 2, Step: 2, Encoding: EncodingMono8, Data: []byte{1, 2, 3, 4}})
+	img, _, err := rs.Read(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds(), test.ShouldResemble, image.Rect(0, 0, 1, 1))
+}
+
+func TestSyncedImagesMaxFps(t *testing.T) {
+	rs := &RosMediaSource{
+		logger:        logging.NewTestLogger(t),
+		syncTolerance: 5 * time.Millisecond,
+		syncLimiter:   newRateLimiter(15),
+	}
+	left := newNamedStream(NamedTopicConfig{Name: "left", Topic: "/stereo/left/image_raw"})
+	right := newNamedStream(NamedTopicConfig{Name: "right", Topic: "/stereo/right/image_raw"})
+	rs.streams = []*namedStream{left, right}
+	onLeft := rs.streamCallback(left).(func(*sensor_msgs.Image))
+	onRight := rs.streamCallback(right).(func(*sensor_msgs.Image))
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	publish := func(callback func(*sensor_msgs.Image), stamp time.Time) {
+		msg := &sensor_msgs.Image{Width: 1, Height: 1, Step: 1, Encoding: EncodingMono8, Data: []byte{1}}
+		msg.Header.Stamp = stamp
+		callback(msg)
+	}
+
+	// a 30fps stereo pair with jitter, the right frame always arrives first
+	returned := map[time.Time]bool{}
+	for i := 0; i < 60; i++ {
+		stamp := base.Add(time.Duration(i)*time.Second/30 + time.Duration(i%3-1)*time.Millisecond)
+		publish(onRight, stamp)
+		publish(onLeft, stamp.Add(time.Millisecond))
+		images, meta, err := rs.Images(context.Background())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(images), test.ShouldEqual, 2)
+		returned[meta.CapturedAt] = true
+	}
+	// two seconds of frames give 30 distinct sets at 15fps
+	test.That(t, len(returned), test.ShouldEqual, 30)
+}
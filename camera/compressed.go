@@ -0,0 +1,75 @@
+package camera
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/utils"
+)
+
+/*
+ * sensor_msgs/CompressedImage
+ * The compressed bytes are kept as a lazy image: a JPEG request returns them
+ * unchanged and they are only decoded when pixels or another format are needed.
+ * The compressedDepth transport adds its own header and is not supported.
+ */
+
+var (
+	jpegMagic = []byte{0xff, 0xd8, 0xff}
+	pngMagic  = []byte{0x89, 'P', 'N', 'G'}
+)
+
+// compressedTopic reports if the topic carries CompressedImage messages by default,
+// image_transport publishes them on <topic>/compressed
+func compressedTopic(topic string) bool {
+	return strings.HasSuffix(topic, "/compressed")
+}
+
+// compressedMimeType returns the mime type from the format, i.e. "jpeg" or
+// "bgr8; jpeg compressed bgr8", falling back to the magic bytes
+func compressedMimeType(format string, data []byte) (string, error) {
+	f := strings.ToLower(format)
+	switch {
+	case strings.Contains(f, "compresseddepth"):
+		return "", fmt.Errorf("unsupported compressed image format %q", format)
+	case strings.Contains(f, "jpeg"), strings.Contains(f, "jpg"):
+		return utils.MimeTypeJPEG, nil
+	case strings.Contains(f, "png"):
+		return utils.MimeTypePNG, nil
+	case bytes.HasPrefix(data, jpegMagic):
+		return utils.MimeTypeJPEG, nil
+	case bytes.HasPrefix(data, pngMagic):
+		return utils.MimeTypePNG, nil
+	default:
+		return "", fmt.Errorf("unsupported compressed image format %q", format)
+	}
+}
+
+func (rs *RosMediaSource) updateImageFromCompressedMsg(msg *sensor_msgs.CompressedImage) {
+	f := rs.compressedFrame(msg)
+	if f == nil {
+		return
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.limiter.allow(f.received) {
+		rs.current = f
+	}
+}
+
+// compressedFrame wraps the compressed bytes, nil for empty or unsupported messages
+func (rs *RosMediaSource) compressedFrame(msg *sensor_msgs.CompressedImage) *frame {
+	if msg == nil || len(msg.Data) == 0 {
+		rs.logger.Warn("ROS compressed image data not ready")
+		return nil
+	}
+	mimeType, err := compressedMimeType(msg.Format, msg.Data)
+	if err != nil {
+		rs.logger.Warnf("unable to use ROS compressed image: %v", err)
+		return nil
+	}
+	return newFrame(rimage.NewLazyEncodedImage(msg.Data, mimeType), nil, msg.Header.Stamp)
+}
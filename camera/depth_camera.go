@@ -0,0 +1,377 @@
+package camera
+
+/*
+ * RosDepthCamera
+ * Combines a ROS depth image, an optional aligned color image and the
+ * CameraInfo of the depth image. Images returns both streams when their
+ * header stamps are within sync_tolerance_ms, point clouds are projected
+ * from the depth through the intrinsics and colored when the color image
+ * has the same size and is within the tolerance of the depth image.
+ */
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/brokenrobotz/viam-ros-module/viamrosnode"
+	viamcamera "go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+var RosDepthCameraModel = resource.NewModel("brokenrobotz", "ros", "depth-camera")
+
+// names of the streams returned by Images
+const (
+	colorSourceName = "color"
+	depthSourceName = "depth"
+)
+
+type RosDepthCamera struct {
+	resource.Named
+
+	mu              sync.Mutex
+	primaryUri      string
+	depthTopic      string
+	colorTopic      string
+	infoTopic       string
+	node            *goroslib.Node
+	depthSubscriber *goroslib.Subscriber
+	colorSubscriber *goroslib.Subscriber
+	infoSubscriber  *goroslib.Subscriber
+	depthMsg        *sensor_msgs.Image // raw images waiting to be converted
+	colorMsg        *sensor_msgs.Image
+	depthStamp      time.Time
+	colorStamp      time.Time
+	syncTolerance   time.Duration
+	depth           *rimage.DepthMap
+	color           image.Image
+	intrinsics      *transform.PinholeCameraIntrinsics
+	distortion      *transform.BrownConrady
+	logger          logging.Logger
+}
+
+func init() {
+	resource.RegisterComponent(
+		viamcamera.API,
+		RosDepthCameraModel,
+		resource.Registration[viamcamera.Camera, *RosDepthCameraConfig]{
+			Constructor: NewRosDepthCamera,
+		},
+	)
+}
+
+func NewRosDepthCamera(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (viamcamera.Camera, error) {
+	dc := &RosDepthCamera{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+	}
+
+	if err := dc.Reconfigure(ctx, deps, conf); err != nil {
+		return nil, err
+	}
+
+	// the stream shows the color image when there is one
+	stream := viamcamera.DepthStream
+	if dc.colorTopic != "" {
+		stream = viamcamera.ColorStream
+	}
+	camModel := viamcamera.NewPinholeModelWithBrownConradyDistortion(nil, nil)
+	videoSrc, err := viamcamera.NewVideoSourceFromReader(ctx, dc, &camModel, stream)
+	if err != nil {
+		return nil, err
+	}
+	return &rosCamera{
+		Camera: viamcamera.FromVideoSource(conf.ResourceName(), videoSrc, logger),
+		source: dc,
+	}, nil
+}
+
+func (dc *RosDepthCamera) Reconfigure(
+	_ context.Context,
+	_ resource.Dependencies,
+	conf resource.Config,
+) error {
+	var err error
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.primaryUri = conf.Attributes.String("primary_uri")
+	dc.depthTopic = conf.Attributes.String("depth_topic")
+	dc.colorTopic = strings.TrimSpace(conf.Attributes.String("color_topic"))
+	dc.infoTopic = conf.Attributes.String("camera_info_topic")
+	dc.syncTolerance = time.Duration(conf.Attributes.Int("sync_tolerance_ms", defaultSyncToleranceMs)) * time.Millisecond
+
+	if len(strings.TrimSpace(dc.primaryUri)) == 0 {
+		return errors.New("ROS primary uri must be set to hostname:port")
+	}
+
+	if len(strings.TrimSpace(dc.depthTopic)) == 0 {
+		return errors.New("ROS depth topic must be set to valid depth image topic")
+	}
+
+	if len(strings.TrimSpace(dc.infoTopic)) == 0 {
+		dc.infoTopic = defaultCameraInfoTopic(dc.depthTopic)
+	}
+
+	dc.closeSubscribers()
+	dc.depthMsg = nil
+	dc.colorMsg = nil
+	dc.depth = nil
+	dc.color = nil
+	dc.intrinsics = nil
+	dc.distortion = nil
+
+	dc.node, err = viamrosnode.GetInstance(dc.primaryUri)
+	if err != nil {
+		return err
+	}
+
+	dc.depthSubscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+		Node:     dc.node,
+		Topic:    dc.depthTopic,
+		Callback: dc.processDepth,
+	})
+	if err != nil {
+		return err
+	}
+
+	if dc.colorTopic != "" {
+		dc.colorSubscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+			Node:     dc.node,
+			Topic:    dc.colorTopic,
+			Callback: dc.processColor,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	dc.infoSubscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+		Node:     dc.node,
+		Topic:    dc.infoTopic,
+		Callback: dc.processCameraInfo,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// closeSubscribers closes all subscribers, caller must hold dc.mu
+func (dc *RosDepthCamera) closeSubscribers() {
+	for _, s := range []*goroslib.Subscriber{dc.depthSubscriber, dc.colorSubscriber, dc.infoSubscriber} {
+		if s != nil {
+			s.Close()
+		}
+	}
+	dc.depthSubscriber = nil
+	dc.colorSubscriber = nil
+	dc.infoSubscriber = nil
+}
+
+func (dc *RosDepthCamera) processDepth(msg *sensor_msgs.Image) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.depthMsg = msg
+	dc.depthStamp = captureTime(msg.Header.Stamp, time.Now())
+	dc.depth = nil
+}
+
+func (dc *RosDepthCamera) processColor(msg *sensor_msgs.Image) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.colorMsg = msg
+	dc.colorStamp = captureTime(msg.Header.Stamp, time.Now())
+	dc.color = nil
+}
+
+func (dc *RosDepthCamera) processCameraInfo(msg *sensor_msgs.CameraInfo) {
+	intrinsics := cameraInfoIntrinsics(msg)
+	distortion := cameraInfoDistortion(msg)
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.intrinsics = intrinsics
+	dc.distortion = distortion
+}
+
+// cameraModel returns the last intrinsics and distortion received
+func (dc *RosDepthCamera) cameraModel() (*transform.PinholeCameraIntrinsics, *transform.BrownConrady) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.intrinsics, dc.distortion
+}
+
+// depthFrames is one locked snapshot of the last depth map and color image with their
+// header stamps, either image may be nil
+type depthFrames struct {
+	depth      *rimage.DepthMap
+	color      image.Image
+	depthStamp time.Time
+	colorStamp time.Time
+	tolerance  time.Duration
+}
+
+// matched reports if both images are there and taken within the sync tolerance
+func (f depthFrames) matched() bool {
+	return f.depth != nil && f.color != nil && absDuration(f.depthStamp.Sub(f.colorStamp)) <= f.tolerance
+}
+
+// latest converts any new raw images and returns the last depth map and color image
+func (dc *RosDepthCamera) latest() (depthFrames, error) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.depthMsg != nil {
+		dm, err := toDepthMap(dc.depthMsg)
+		if err != nil {
+			return depthFrames{}, err
+		}
+		dc.depth = dm
+		dc.depthMsg = nil
+	}
+	if dc.colorMsg != nil {
+		img, err := convertImage(dc.colorMsg)
+		if err != nil {
+			return depthFrames{}, err
+		}
+		dc.color = img
+		dc.colorMsg = nil
+	}
+	return depthFrames{
+		depth:      dc.depth,
+		color:      dc.color,
+		depthStamp: dc.depthStamp,
+		colorStamp: dc.colorStamp,
+		tolerance:  dc.syncTolerance,
+	}, nil
+}
+
+// Read returns the color image, or the depth map when there is no color topic
+func (dc *RosDepthCamera) Read(_ context.Context) (image.Image, func(), error) {
+	frames, err := dc.latest()
+	if err != nil {
+		return nil, nil, err
+	}
+	if dc.colorTopic != "" {
+		if frames.color == nil {
+			return nil, nil, errors.New("color image is not ready")
+		}
+		return frames.color, func() {}, nil
+	}
+	if frames.depth == nil {
+		return nil, nil, errors.New("depth image is not ready")
+	}
+	return frames.depth, func() {}, nil
+}
+
+func (dc *RosDepthCamera) Images(_ context.Context) ([]viamcamera.NamedImage, resource.ResponseMetadata, error) {
+	frames, err := dc.latest()
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	if frames.depth != nil && frames.color != nil && !frames.matched() {
+		return nil, resource.ResponseMetadata{}, fmt.Errorf("color and depth images are %s apart",
+			absDuration(frames.depthStamp.Sub(frames.colorStamp)))
+	}
+	var images []viamcamera.NamedImage
+	stamp := frames.depthStamp
+	if frames.color != nil {
+		images = append(images, viamcamera.NamedImage{Image: frames.color, SourceName: colorSourceName})
+		stamp = frames.colorStamp
+	}
+	if frames.depth != nil {
+		images = append(images, viamcamera.NamedImage{Image: frames.depth, SourceName: depthSourceName})
+		stamp = frames.depthStamp
+	}
+	if len(images) == 0 {
+		return nil, resource.ResponseMetadata{}, errors.New("images are not ready")
+	}
+	return images, resource.ResponseMetadata{CapturedAt: stamp}, nil
+}
+
+func (dc *RosDepthCamera) NextPointCloud(_ context.Context) (pointcloud.PointCloud, error) {
+	intrinsics, _ := dc.cameraModel()
+	if intrinsics == nil {
+		return nil, errors.New("camera info is not ready")
+	}
+	frames, err := dc.latest()
+	if err != nil {
+		return nil, err
+	}
+	if frames.depth == nil {
+		return nil, errors.New("depth image is not ready")
+	}
+	// a color image from another moment would paint the wrong points
+	colorImg := frames.color
+	if !frames.matched() {
+		colorImg = nil
+	}
+	return projectDepth(intrinsics, frames.depth, colorImg)
+}
+
+func (dc *RosDepthCamera) Close(_ context.Context) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.closeSubscribers()
+	return nil
+}
+
+// toDepthMap converts a 16UC1 (mm) or 32FC1 (m) image to a depth map in mm
+func toDepthMap(msg *sensor_msgs.Image) (*rimage.DepthMap, error) {
+	switch msg.Encoding {
+	case Encoding16UC1, EncodingMono16, Encoding32FC1:
+	default:
+		return nil, fmt.Errorf("unsupported depth image encoding %q", msg.Encoding)
+	}
+	ri, err := newRosImage(msg)
+	if err != nil {
+		return nil, err
+	}
+	return rimage.ConvertImageToDepthMap(context.Background(), ri.toImage())
+}
+
+// projectDepth projects every pixel with a depth to a point in mm, points are colored
+// from img when it matches the depth map size
+func projectDepth(
+	intrinsics *transform.PinholeCameraIntrinsics,
+	dm *rimage.DepthMap,
+	img image.Image,
+) (pointcloud.PointCloud, error) {
+	if img != nil && img.Bounds() != dm.Bounds() {
+		img = nil
+	}
+	pc := pointcloud.New()
+	for y := 0; y < dm.Height(); y++ {
+		for x := 0; x < dm.Width(); x++ {
+			z := dm.GetDepth(x, y)
+			if z == 0 {
+				continue
+			}
+			px, py, pz := intrinsics.PixelToPoint(float64(x), float64(y), float64(z))
+			var d pointcloud.Data
+			if img != nil {
+				r, g, b, _ := img.At(x, y).RGBA()
+				d = pointcloud.NewColoredData(color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 255})
+			}
+			if err := pc.Set(pointcloud.NewVector(px, py, pz), d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return pc, nil
+}
@@ -0,0 +1,33 @@
+package camera
+
+import "fmt"
+
+type RosDepthCameraConfig struct {
+	NodeName   string `json:"node_name"`
+	PrimaryUri string `json:"primary_uri"`
+	// depth image in 16UC1 millimeters or 32FC1 meters
+	DepthTopic string `json:"depth_topic"`
+	// optional color image aligned to the depth image
+	ColorTopic string `json:"color_topic"`
+	// sensor_msgs/CameraInfo of the depth image, defaults to camera_info next to the depth topic
+	CameraInfoTopic string `json:"camera_info_topic"`
+	// largest stamp difference between the depth and color images, defaults to 50
+	SyncTolerance *int `json:"sync_tolerance_ms,omitempty"`
+}
+
+func (cfg *RosDepthCameraConfig) Validate(path string) ([]string, error) {
+	// NodeName will get default value if string is empty
+	if cfg.PrimaryUri == "" {
+		return nil, fmt.Errorf(`expected "PrimaryUri" attribute for sensor %q`, path)
+	}
+
+	if cfg.DepthTopic == "" {
+		return nil, fmt.Errorf(`expected "depth_topic" attribute for sensor %q`, path)
+	}
+
+	if cfg.SyncTolerance != nil && *cfg.SyncTolerance < 0 {
+		return nil, fmt.Errorf(`"sync_tolerance_ms" can not be negative for sensor %q`, path)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,129 @@
+package camera
+
+import (
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/rimage/transform"
+	"go.viam.com/test"
+)
+
+func TestDepthMap(t *testing.T) {
+	// 2x1 16UC1 in mm
+	dm, err := toDepthMap(&sensor_msgs.Image{
+		Width: 2, Height: 1, Step: 4, Encoding: Encoding16UC1, Data: []byte{0xe8, 0x03, 0, 0},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dm.Width(), test.ShouldEqual, 2)
+	test.That(t, dm.Height(), test.ShouldEqual, 1)
+	test.That(t, dm.GetDepth(0, 0), test.ShouldEqual, rimage.Depth(1000))
+
+	// 1x2 32FC1 in meters
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data, math.Float32bits(0.25))
+	binary.LittleEndian.PutUint32(data[4:], math.Float32bits(2))
+	dm, err = toDepthMap(&sensor_msgs.Image{Width: 1, Height: 2, Step: 4, Encoding: Encoding32FC1, Data: data})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, dm.GetDepth(0, 0), test.ShouldEqual, rimage.Depth(250))
+	test.That(t, dm.GetDepth(0, 1), test.ShouldEqual, rimage.Depth(2000))
+
+	_, err = toDepthMap(&sensor_msgs.Image{Width: 1, Height: 1, Step: 3, Encoding: EncodingRGB8, Data: []byte{1, 2, 3}})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestDepthCameraPointCloud(t *testing.T) {
+	intrinsics := &transform.PinholeCameraIntrinsics{Width: 2, Height: 2, Fx: 1, Fy: 1, Ppx: 0, Ppy: 0}
+	dm := rimage.NewEmptyDepthMap(2, 2)
+	dm.Set(1, 1, 1000)
+	dm.Set(0, 1, 500)
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+
+	// pixels without depth are skipped
+	pc, err := projectDepth(intrinsics, dm, img)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+	d, ok := pc.At(1000, 1000, 1000)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, d.HasColor(), test.ShouldBeTrue)
+	r, _, _ := d.RGB255()
+	test.That(t, r, test.ShouldEqual, 255)
+
+	// a color image of another size is ignored
+	pc, err = projectDepth(intrinsics, dm, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	test.That(t, err, test.ShouldBeNil)
+	d, ok = pc.At(0, 500, 500)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, d == nil || !d.HasColor(), test.ShouldBeTrue)
+
+	dc := &RosDepthCamera{logger: logging.NewTestLogger(t), colorTopic: "/camera/rgb/image_raw"}
+	_, err = dc.NextPointCloud(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = dc.Images(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+
+	dc.processCameraInfo(&sensor_msgs.CameraInfo{Width: 2, Height: 2, K: [9]float64{1, 0, 0, 0, 1, 0, 0, 0, 1}})
+	dc.depth = dm
+	dc.color = img
+	images, _, err := dc.Images(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 2)
+	test.That(t, images[0].SourceName, test.ShouldEqual, colorSourceName)
+	test.That(t, images[1].SourceName, test.ShouldEqual, depthSourceName)
+
+	pc, err = dc.NextPointCloud(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+	cam := &rosCamera{source: dc}
+	projector, err := cam.Projector(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	p, err := projector.ImagePointTo3DPoint(image.Point{X: 1, Y: 1}, 1000)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, p, test.ShouldResemble, r3.Vector{X: 1000, Y: 1000, Z: 1000})
+}
+
+func TestDepthCameraImagesStamps(t *testing.T) {
+	dm := rimage.NewEmptyDepthMap(2, 2)
+	dm.Set(1, 1, 1000)
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	stamp := time.Unix(100, 0)
+
+	dc := &RosDepthCamera{logger: logging.NewTestLogger(t), colorTopic: "/camera/rgb/image_raw", syncTolerance: 50 * time.Millisecond}
+	dc.processCameraInfo(&sensor_msgs.CameraInfo{Width: 2, Height: 2, K: [9]float64{1, 0, 0, 0, 1, 0, 0, 0, 1}})
+
+	// only color, the capture time is its stamp
+	dc.color, dc.colorStamp = img, stamp
+	images, meta, err := dc.Images(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 1)
+	test.That(t, meta.CapturedAt, test.ShouldEqual, stamp)
+
+	// within the tolerance both are returned with the depth stamp
+	dc.depth, dc.depthStamp = dm, stamp.Add(30*time.Millisecond)
+	images, meta, err = dc.Images(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 2)
+	test.That(t, meta.CapturedAt, test.ShouldEqual, dc.depthStamp)
+
+	// a color frame from another moment is not paired with the depth
+	dc.depthStamp = stamp.Add(200 * time.Millisecond)
+	_, _, err = dc.Images(context.Background())
+	test.That(t, err, test.ShouldNotBeNil)
+	pc, err := dc.NextPointCloud(context.Background())
+	test.That(t, err, test.ShouldBeNil)
+	d, ok := pc.At(1000, 1000, 1000)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, d == nil || !d.HasColor(), test.ShouldBeTrue)
+}
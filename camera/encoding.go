@@ -0,0 +1,174 @@
+package camera
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+/*
+ * sensor_msgs/Image encodings
+ * The names come from sensor_msgs/image_encodings.h. Color encodings
+ * decode to RGBA, mono8 to Gray and the 16 bit and float encodings to Gray16.
+ * 32FC1 is a depth image in meters and is stored as millimeters.
+ * Bayer images are demosaiced per 2x2 block, which halves the color
+ * resolution but needs no neighbouring blocks.
+ */
+const (
+	EncodingRGB8   = "rgb8"
+	EncodingBGR8   = "bgr8"
+	EncodingRGBA8  = "rgba8"
+	EncodingBGRA8  = "bgra8"
+	EncodingMono8  = "mono8"
+	EncodingMono16 = "mono16"
+	Encoding16UC1  = "16UC1"
+	Encoding32FC1  = "32FC1"
+	EncodingYUV422 = "yuv422"
+)
+
+const bayerPrefix = "bayer_"
+
+// bytesPerPixel returns the size of one pixel for the encoding, bayer encodings are
+// bayer_<pattern>8 or bayer_<pattern>16
+func bytesPerPixel(encoding string) (int, error) {
+	switch encoding {
+	case EncodingRGB8, EncodingBGR8:
+		return 3, nil
+	case EncodingRGBA8, EncodingBGRA8, Encoding32FC1:
+		return 4, nil
+	case EncodingMono8:
+		return 1, nil
+	case EncodingMono16, Encoding16UC1, EncodingYUV422:
+		return 2, nil
+	}
+	if _, ok := bayerPattern(encoding); ok {
+		if strings.HasSuffix(encoding, "16") {
+			return 2, nil
+		}
+		return 1, nil
+	}
+	return 0, fmt.Errorf("unsupported image encoding %q", encoding)
+}
+
+// bayerPattern returns the 2x2 filter pattern, i.e. "rggb", of a bayer encoding
+func bayerPattern(encoding string) (string, bool) {
+	if !strings.HasPrefix(encoding, bayerPrefix) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(encoding, bayerPrefix)
+	if !strings.HasSuffix(rest, "8") && !strings.HasSuffix(rest, "16") {
+		return "", false
+	}
+	pattern := strings.TrimRight(rest, "0123456789")
+	switch pattern {
+	case "rggb", "bggr", "gbrg", "grbg":
+		return pattern, true
+	default:
+		return "", false
+	}
+}
+
+// colorModel returns the model the encoding decodes to
+func colorModel(encoding string) color.Model {
+	switch {
+	case encoding == EncodingMono8:
+		return color.GrayModel
+	case encoding == EncodingMono16, encoding == Encoding16UC1, encoding == Encoding32FC1:
+		return color.Gray16Model
+	default:
+		return color.RGBAModel
+	}
+}
+
+func (rosImage *RosImage) byteOrder() binary.ByteOrder {
+	if rosImage.bigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// offset returns the index of the first byte of the pixel
+func (rosImage *RosImage) offset(row, col int) int {
+	return row*rosImage.step + col*rosImage.bytesPerPixel
+}
+
+// sample reads a single channel bayer value scaled to 8 bits
+func (rosImage *RosImage) sample(row, col int) uint8 {
+	i := rosImage.offset(row, col)
+	if rosImage.bytesPerPixel == 2 {
+		return uint8(rosImage.byteOrder().Uint16(rosImage.data[i:]) >> 8)
+	}
+	return rosImage.data[i]
+}
+
+// decode returns the color of the pixel at row, col
+func (rosImage *RosImage) decode(row, col int) color.Color {
+	d := rosImage.data
+	i := rosImage.offset(row, col)
+	switch rosImage.encoding {
+	case EncodingRGB8:
+		return color.RGBA{R: d[i], G: d[i+1], B: d[i+2], A: 255}
+	case EncodingBGR8:
+		return color.RGBA{R: d[i+2], G: d[i+1], B: d[i], A: 255}
+	case EncodingRGBA8:
+		return color.NRGBA{R: d[i], G: d[i+1], B: d[i+2], A: d[i+3]}
+	case EncodingBGRA8:
+		return color.NRGBA{R: d[i+2], G: d[i+1], B: d[i], A: d[i+3]}
+	case EncodingMono8:
+		return color.Gray{Y: d[i]}
+	case EncodingMono16, Encoding16UC1:
+		return color.Gray16{Y: rosImage.byteOrder().Uint16(d[i:])}
+	case Encoding32FC1:
+		meters := math.Float32frombits(rosImage.byteOrder().Uint32(d[i:]))
+		return color.Gray16{Y: metersToMillimeters(float64(meters))}
+	case EncodingYUV422:
+		// UYVY, each pair of pixels shares u and v
+		pair := rosImage.offset(row, col&^1)
+		y := d[pair+1]
+		if col&1 == 1 {
+			y = d[pair+3]
+		}
+		r, g, b := color.YCbCrToRGB(y, d[pair], d[pair+2])
+		return color.RGBA{R: r, G: g, B: b, A: 255}
+	default:
+		return rosImage.demosaic(row, col)
+	}
+}
+
+// demosaic colors every pixel of a 2x2 bayer block the same, the greens are averaged
+func (rosImage *RosImage) demosaic(row, col int) color.Color {
+	pattern, _ := bayerPattern(rosImage.encoding)
+	r0, c0 := row&^1, col&^1
+	// an odd sized image has a partial last block, reuse the previous one
+	if r0+1 >= rosImage.height {
+		r0 = max(r0-2, 0)
+	}
+	if c0+1 >= rosImage.width {
+		c0 = max(c0-2, 0)
+	}
+	var red, blue uint8
+	var green int
+	for k, channel := range pattern {
+		v := rosImage.sample(r0+k/2, c0+k%2)
+		switch channel {
+		case 'r':
+			red = v
+		case 'b':
+			blue = v
+		default:
+			green += int(v)
+		}
+	}
+	return color.RGBA{R: red, G: uint8(green / 2), B: blue, A: 255}
+}
+
+// metersToMillimeters converts a float depth, invalid and out of range values are 0
+func metersToMillimeters(m float64) uint16 {
+	mm := m * 1000
+	if math.IsNaN(mm) || mm <= 0 || mm > math.MaxUint16 {
+		return 0
+	}
+	return uint16(math.Round(mm))
+}
@@ -0,0 +1,336 @@
+package camera
+
+/*
+ * RosPointCloud2
+ * Converts sensor_msgs/PointCloud2 messages, as published by 3D lidars and
+ * RGB-D cameras, to viam point clouds in mm. The point layout comes from
+ * the message fields: x, y and z are required, intensity and a packed rgb
+ * or rgba (or separate r, g and b fields) are kept when present. Fields may
+ * use any datatype and either byte order. Points with a NaN or infinite
+ * coordinate are skipped, drivers use them for missing returns.
+ */
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/bluenviron/goroslib/v2"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/brokenrobotz/viam-ros-module/viamrosnode"
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/gostream"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage/transform"
+)
+
+var RosPointCloud2Model = resource.NewModel("brokenrobotz", "ros", "pointcloud2")
+
+type RosPointCloud2 struct {
+	resource.Named
+
+	mu         sync.Mutex
+	primaryUri string
+	topic      string
+	node       *goroslib.Node
+	subscriber *goroslib.Subscriber
+	msg        *sensor_msgs.PointCloud2
+	logger     logging.Logger
+}
+
+func init() {
+	resource.RegisterComponent(
+		camera.API,
+		RosPointCloud2Model,
+		resource.Registration[camera.Camera, *RosPointCloud2Config]{
+			Constructor: NewRosPointCloud2,
+		},
+	)
+}
+
+func NewRosPointCloud2(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (camera.Camera, error) {
+	p := &RosPointCloud2{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+	}
+
+	if err := p.Reconfigure(ctx, deps, conf); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *RosPointCloud2) Reconfigure(
+	_ context.Context,
+	_ resource.Dependencies,
+	conf resource.Config,
+) error {
+	var err error
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.primaryUri = conf.Attributes.String("primary_uri")
+	p.topic = conf.Attributes.String("topic")
+
+	if len(strings.TrimSpace(p.primaryUri)) == 0 {
+		return errors.New("ROS primary uri must be set to hostname:port")
+	}
+
+	if len(strings.TrimSpace(p.topic)) == 0 {
+		return errors.New("ROS topic must be set to valid point cloud topic")
+	}
+
+	if p.subscriber != nil {
+		p.subscriber.Close()
+	}
+
+	p.node, err = viamrosnode.GetInstance(p.primaryUri)
+	if err != nil {
+		return err
+	}
+
+	p.msg = nil
+	p.subscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+		Node:     p.node,
+		Topic:    p.topic,
+		Callback: p.processMessage,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// processMessage keeps the raw message, it is only converted when a point cloud is requested
+func (p *RosPointCloud2) processMessage(msg *sensor_msgs.PointCloud2) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msg = msg
+}
+
+func (p *RosPointCloud2) Projector(_ context.Context) (transform.Projector, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *RosPointCloud2) Images(_ context.Context) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	return nil, resource.ResponseMetadata{}, fmt.Errorf("not implemented")
+}
+
+func (p *RosPointCloud2) Stream(_ context.Context, _ ...gostream.ErrorHandler) (gostream.VideoStream, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *RosPointCloud2) NextPointCloud(_ context.Context) (pointcloud.PointCloud, error) {
+	p.mu.Lock()
+	msg := p.msg
+	p.mu.Unlock()
+
+	if msg == nil {
+		return nil, errors.New("point cloud is not ready")
+	}
+	return convertPointCloud2(msg)
+}
+
+func (p *RosPointCloud2) Properties(_ context.Context) (camera.Properties, error) {
+	return camera.Properties{
+		SupportsPCD: true,
+	}, nil
+}
+
+func (p *RosPointCloud2) Close(_ context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.subscriber != nil {
+		p.subscriber.Close()
+	}
+	return nil
+}
+
+// pointFieldSize returns the size in bytes of one value of the PointField datatype
+func pointFieldSize(datatype uint8) (int, error) {
+	switch datatype {
+	case sensor_msgs.PointField_INT8, sensor_msgs.PointField_UINT8:
+		return 1, nil
+	case sensor_msgs.PointField_INT16, sensor_msgs.PointField_UINT16:
+		return 2, nil
+	case sensor_msgs.PointField_INT32, sensor_msgs.PointField_UINT32, sensor_msgs.PointField_FLOAT32:
+		return 4, nil
+	case sensor_msgs.PointField_FLOAT64:
+		return 8, nil
+	default:
+		return 0, fmt.Errorf("unsupported point field datatype %d", datatype)
+	}
+}
+
+// pointLayout holds the fields used from each point, nil when the cloud has no such field
+type pointLayout struct {
+	x, y, z   *sensor_msgs.PointField
+	intensity *sensor_msgs.PointField
+	rgb       *sensor_msgs.PointField // packed 0xAARRGGBB
+	hasAlpha  bool
+	r, g, b   *sensor_msgs.PointField
+	order     binary.ByteOrder
+}
+
+// newPointLayout finds the fields by name and checks they fit in point_step
+func newPointLayout(msg *sensor_msgs.PointCloud2) (*pointLayout, error) {
+	layout := &pointLayout{order: binary.LittleEndian}
+	if msg.IsBigendian {
+		layout.order = binary.BigEndian
+	}
+	for i := range msg.Fields {
+		f := &msg.Fields[i]
+		size, err := pointFieldSize(f.Datatype)
+		if err != nil {
+			if f.Name == "x" || f.Name == "y" || f.Name == "z" {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			// padding and unknown fields are not read
+			continue
+		}
+		if int(f.Offset)+size > int(msg.PointStep) {
+			return nil, fmt.Errorf("field %s at offset %d does not fit in point step %d", f.Name, f.Offset, msg.PointStep)
+		}
+		switch f.Name {
+		case "x":
+			layout.x = f
+		case "y":
+			layout.y = f
+		case "z":
+			layout.z = f
+		case "intensity", "i":
+			layout.intensity = f
+		case "rgb", "rgba":
+			if size != 4 {
+				return nil, fmt.Errorf("field %s must be 4 bytes", f.Name)
+			}
+			layout.rgb = f
+			layout.hasAlpha = f.Name == "rgba"
+		case "r":
+			layout.r = f
+		case "g":
+			layout.g = f
+		case "b":
+			layout.b = f
+		}
+	}
+	if layout.x == nil || layout.y == nil || layout.z == nil {
+		return nil, errors.New("point cloud must have x, y and z fields")
+	}
+	return layout, nil
+}
+
+// value reads the field of the point as a float64
+func (layout *pointLayout) value(point []byte, f *sensor_msgs.PointField) float64 {
+	b := point[f.Offset:]
+	switch f.Datatype {
+	case sensor_msgs.PointField_INT8:
+		return float64(int8(b[0]))
+	case sensor_msgs.PointField_UINT8:
+		return float64(b[0])
+	case sensor_msgs.PointField_INT16:
+		return float64(int16(layout.order.Uint16(b)))
+	case sensor_msgs.PointField_UINT16:
+		return float64(layout.order.Uint16(b))
+	case sensor_msgs.PointField_INT32:
+		return float64(int32(layout.order.Uint32(b)))
+	case sensor_msgs.PointField_UINT32:
+		return float64(layout.order.Uint32(b))
+	case sensor_msgs.PointField_FLOAT32:
+		return float64(math.Float32frombits(layout.order.Uint32(b)))
+	default:
+		return math.Float64frombits(layout.order.Uint64(b))
+	}
+}
+
+// color returns the color of the point, false when the cloud has no color
+func (layout *pointLayout) color(point []byte) (color.NRGBA, bool) {
+	switch {
+	case layout.rgb != nil:
+		// the packed color is the bits of the field, whatever its datatype
+		packed := layout.order.Uint32(point[layout.rgb.Offset:])
+		c := color.NRGBA{R: uint8(packed >> 16), G: uint8(packed >> 8), B: uint8(packed), A: 255}
+		if layout.hasAlpha {
+			c.A = uint8(packed >> 24)
+		}
+		return c, true
+	case layout.r != nil && layout.g != nil && layout.b != nil:
+		return color.NRGBA{
+			R: clampUint8(layout.value(point, layout.r)),
+			G: clampUint8(layout.value(point, layout.g)),
+			B: clampUint8(layout.value(point, layout.b)),
+			A: 255,
+		}, true
+	default:
+		return color.NRGBA{}, false
+	}
+}
+
+func clampUint8(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(math.MaxUint8, math.Round(v))))
+}
+
+func clampUint16(v float64) uint16 {
+	if math.IsNaN(v) {
+		return 0
+	}
+	return uint16(math.Max(0, math.Min(math.MaxUint16, math.Round(v))))
+}
+
+// convertPointCloud2 converts the points to mm, keeping color and intensity
+func convertPointCloud2(msg *sensor_msgs.PointCloud2) (pointcloud.PointCloud, error) {
+	layout, err := newPointLayout(msg)
+	if err != nil {
+		return nil, err
+	}
+	width, height := int(msg.Width), int(msg.Height)
+	pointStep, rowStep := int(msg.PointStep), int(msg.RowStep)
+	if height > 0 && width > 0 {
+		if rowStep < width*pointStep {
+			return nil, fmt.Errorf("row step %d is too small for %d points of %d bytes", rowStep, width, pointStep)
+		}
+		if len(msg.Data) < (height-1)*rowStep+width*pointStep {
+			return nil, fmt.Errorf("point cloud data has %d bytes, expected %d", len(msg.Data), height*rowStep)
+		}
+	}
+
+	pc := pointcloud.NewWithPrealloc(width * height)
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			start := row*rowStep + col*pointStep
+			point := msg.Data[start : start+pointStep]
+			x, y, z := layout.value(point, layout.x), layout.value(point, layout.y), layout.value(point, layout.z)
+			if !isFinite(x) || !isFinite(y) || !isFinite(z) {
+				continue
+			}
+
+			d := pointcloud.NewBasicData()
+			if c, ok := layout.color(point); ok {
+				d.SetColor(c)
+			}
+			if layout.intensity != nil {
+				d.SetIntensity(clampUint16(layout.value(point, layout.intensity)))
+			}
+			if err := pc.Set(r3.Vector{X: x * 1000, Y: y * 1000, Z: z * 1000}, d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return pc, nil
+}
+
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
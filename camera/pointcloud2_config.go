@@ -0,0 +1,23 @@
+package camera
+
+import "fmt"
+
+type RosPointCloud2Config struct {
+	NodeName   string `json:"node_name"`
+	PrimaryUri string `json:"primary_uri"`
+	// sensor_msgs/PointCloud2 topic
+	Topic string `json:"topic"`
+}
+
+func (cfg *RosPointCloud2Config) Validate(path string) ([]string, error) {
+	// NodeName will get default value if string is empty
+	if cfg.PrimaryUri == "" {
+		return nil, fmt.Errorf(`expected "PrimaryUri" attribute for sensor %q`, path)
+	}
+
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf(`expected "RosTopic" attribute for sensor %q`, path)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,96 @@
+package camera
+
+import (
+	"encoding/binary"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+)
+
+func TestPointCloud2(t *testing.T) {
+	// float32 xyz, uint16 intensity and packed rgb, little endian, 2 bytes of padding
+	fields := []sensor_msgs.PointField{
+		{Name: "x", Offset: 0, Datatype: sensor_msgs.PointField_FLOAT32, Count: 1},
+		{Name: "y", Offset: 4, Datatype: sensor_msgs.PointField_FLOAT32, Count: 1},
+		{Name: "z", Offset: 8, Datatype: sensor_msgs.PointField_FLOAT32, Count: 1},
+		{Name: "rgb", Offset: 12, Datatype: sensor_msgs.PointField_FLOAT32, Count: 1},
+		{Name: "intensity", Offset: 16, Datatype: sensor_msgs.PointField_UINT16, Count: 1},
+	}
+	point := func(x, y, z float32, rgb uint32, intensity uint16) []byte {
+		b := make([]byte, 20)
+		binary.LittleEndian.PutUint32(b[0:], math.Float32bits(x))
+		binary.LittleEndian.PutUint32(b[4:], math.Float32bits(y))
+		binary.LittleEndian.PutUint32(b[8:], math.Float32bits(z))
+		binary.LittleEndian.PutUint32(b[12:], rgb)
+		binary.LittleEndian.PutUint16(b[16:], intensity)
+		return b
+	}
+	var data []byte
+	data = append(data, point(1, 2, 3, 0xff0000, 100)...)
+	data = append(data, point(float32(math.NaN()), 0, 0, 0, 0)...)
+	data = append(data, point(-0.5, 0, 0.25, 0x00ff00, 7)...)
+	// padding at the end of the row
+	data = append(data, 0, 0, 0, 0)
+	msg := &sensor_msgs.PointCloud2{
+		Height: 1, Width: 3, Fields: fields, PointStep: 20, RowStep: 64, Data: data,
+	}
+
+	pc, err := convertPointCloud2(msg)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+	d, ok := pc.At(1000, 2000, 3000)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, d.Color(), test.ShouldResemble, &color.NRGBA{R: 255, A: 255})
+	test.That(t, d.Intensity(), test.ShouldEqual, 100)
+	d, ok = pc.At(-500, 0, 250)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, d.Color(), test.ShouldResemble, &color.NRGBA{G: 255, A: 255})
+	test.That(t, d.Intensity(), test.ShouldEqual, 7)
+
+	// float64 xyz, big endian, organized as 2 rows, no color or intensity
+	be := make([]byte, 2*24)
+	for i, v := range []float64{0.001, 0.002, 0.003, 4, 5, 6} {
+		binary.BigEndian.PutUint64(be[i*8:], math.Float64bits(v))
+	}
+	msg = &sensor_msgs.PointCloud2{
+		Height: 2, Width: 1, IsBigendian: true, PointStep: 24, RowStep: 24, Data: be,
+		Fields: []sensor_msgs.PointField{
+			{Name: "x", Offset: 0, Datatype: sensor_msgs.PointField_FLOAT64, Count: 1},
+			{Name: "y", Offset: 8, Datatype: sensor_msgs.PointField_FLOAT64, Count: 1},
+			{Name: "z", Offset: 16, Datatype: sensor_msgs.PointField_FLOAT64, Count: 1},
+		},
+	}
+	pc, err = convertPointCloud2(msg)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+	d, ok = pc.At(4000, 5000, 6000)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, d.HasColor(), test.ShouldBeFalse)
+	var points []r3.Vector
+	pc.Iterate(0, 0, func(p r3.Vector, _ pointcloud.Data) bool {
+		points = append(points, p)
+		return true
+	})
+	test.That(t, len(points), test.ShouldEqual, 2)
+}
+
+func TestPointCloud2Errors(t *testing.T) {
+	xy := []sensor_msgs.PointField{
+		{Name: "x", Offset: 0, Datatype: sensor_msgs.PointField_FLOAT32, Count: 1},
+		{Name: "y", Offset: 4, Datatype: sensor_msgs.PointField_FLOAT32, Count: 1},
+	}
+	_, err := convertPointCloud2(&sensor_msgs.PointCloud2{Height: 1, Width: 1, Fields: xy, PointStep: 8, RowStep: 8, Data: make([]byte, 8)})
+	test.That(t, err.Error(), test.ShouldContainSubstring, "x, y and z")
+
+	xyz := append(xy, sensor_msgs.PointField{Name: "z", Offset: 8, Datatype: sensor_msgs.PointField_FLOAT32, Count: 1})
+	_, err = convertPointCloud2(&sensor_msgs.PointCloud2{Height: 1, Width: 1, Fields: xyz, PointStep: 8, RowStep: 8, Data: make([]byte, 8)})
+	test.That(t, err.Error(), test.ShouldContainSubstring, "does not fit")
+
+	_, err = convertPointCloud2(&sensor_msgs.PointCloud2{Height: 2, Width: 1, Fields: xyz, PointStep: 12, RowStep: 12, Data: make([]byte, 12)})
+	test.That(t, err.Error(), test.ShouldContainSubstring, "expected 24")
+}
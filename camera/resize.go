@@ -0,0 +1,66 @@
+package camera
+
+import (
+	"image"
+	"math"
+
+	"go.viam.com/rdk/rimage/transform"
+	"golang.org/x/image/draw"
+)
+
+// targetSize returns the size for target_width and target_height, when only one is
+// set the other follows the aspect ratio and when neither is the size is unchanged
+func targetSize(width, height, targetWidth, targetHeight int) (int, int) {
+	switch {
+	case targetWidth > 0 && targetHeight > 0:
+		return targetWidth, targetHeight
+	case targetWidth > 0 && width > 0:
+		return targetWidth, max(1, int(math.Round(float64(height*targetWidth)/float64(width))))
+	case targetHeight > 0 && height > 0:
+		return max(1, int(math.Round(float64(width*targetHeight)/float64(height)))), targetHeight
+	default:
+		return width, height
+	}
+}
+
+// resizeImage scales the image to the target size. Depth (Gray16) images use the
+// nearest pixel so no depth is made up between objects, the rest are interpolated.
+func resizeImage(img image.Image, targetWidth, targetHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := targetSize(bounds.Dx(), bounds.Dy(), targetWidth, targetHeight)
+	if width == bounds.Dx() && height == bounds.Dy() {
+		return img
+	}
+	rect := image.Rect(0, 0, width, height)
+	switch img.(type) {
+	case *image.Gray16:
+		dst := image.NewGray16(rect)
+		draw.NearestNeighbor.Scale(dst, rect, img, bounds, draw.Src, nil)
+		return dst
+	case *image.Gray:
+		dst := image.NewGray(rect)
+		draw.ApproxBiLinear.Scale(dst, rect, img, bounds, draw.Src, nil)
+		return dst
+	default:
+		dst := image.NewRGBA(rect)
+		draw.ApproxBiLinear.Scale(dst, rect, img, bounds, draw.Src, nil)
+		return dst
+	}
+}
+
+// scaleIntrinsics returns the intrinsics of the resized image
+func scaleIntrinsics(in *transform.PinholeCameraIntrinsics, targetWidth, targetHeight int) *transform.PinholeCameraIntrinsics {
+	width, height := targetSize(in.Width, in.Height, targetWidth, targetHeight)
+	if in.Width == 0 || in.Height == 0 || (width == in.Width && height == in.Height) {
+		return in
+	}
+	sx, sy := float64(width)/float64(in.Width), float64(height)/float64(in.Height)
+	return &transform.PinholeCameraIntrinsics{
+		Width:  width,
+		Height: height,
+		Fx:     in.Fx * sx,
+		Fy:     in.Fy * sy,
+		Ppx:    in.Ppx * sx,
+		Ppy:    in.Ppy * sy,
+	}
+}
@@ -1,37 +1,138 @@
 package camera
 
 import (
+	"encoding/binary"
+	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
 )
 
 type RosImage struct {
-	width  int
-	height int
-	step   int
-	data   []byte
+	width         int
+	height        int
+	step          int
+	bytesPerPixel int
+	encoding      string
+	bigEndian     bool
+	data          []byte
+}
+
+// newRosImage checks the encoding and that the data holds every row of the image
+func newRosImage(msg *sensor_msgs.Image) (*RosImage, error) {
+	bpp, err := bytesPerPixel(msg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	ri := &RosImage{
+		width:         int(msg.Width),
+		height:        int(msg.Height),
+		step:          int(msg.Step),
+		bytesPerPixel: bpp,
+		encoding:      msg.Encoding,
+		bigEndian:     msg.IsBigendian != 0,
+		data:          msg.Data,
+	}
+	if ri.step < ri.width*bpp {
+		return nil, fmt.Errorf("image step %d is too small for %d %s pixels", ri.step, ri.width, ri.encoding)
+	}
+	if _, ok := bayerPattern(ri.encoding); ok && ri.height > 0 && (ri.width < 2 || ri.height < 2) {
+		return nil, fmt.Errorf("%s image must be at least 2x2", ri.encoding)
+	}
+	if ri.encoding == EncodingYUV422 && ri.width%2 != 0 {
+		return nil, fmt.Errorf("%s image width must be even", ri.encoding)
+	}
+	if ri.height > 0 && len(ri.data) < (ri.height-1)*ri.step+ri.width*bpp {
+		return nil, fmt.Errorf("image data has %d bytes, expected %d", len(ri.data), ri.height*ri.step)
+	}
+	return ri, nil
 }
 
 func (rosImage *RosImage) ColorModel() color.Model {
-	return color.RGBAModel
+	return colorModel(rosImage.encoding)
 }
 
 func (rosImage *RosImage) Bounds() image.Rectangle {
-	return image.Rectangle{
-		Min: image.Point{X: 0, Y: 0},
-		Max: image.Point{X: rosImage.height, Y: rosImage.width},
-	}
+	return image.Rect(0, 0, rosImage.width, rosImage.height)
 }
 
-func (rosImage *RosImage) At(x, y int) color.RGBA {
-	bytesPerPixel := rosImage.step / rosImage.width
-	pixelOffset := rosImage.width*x + y
-	byteOffset := bytesPerPixel * pixelOffset
+func (rosImage *RosImage) At(x, y int) color.Color {
+	return rosImage.decode(y, x)
+}
+
+// row returns the pixel bytes of a row without the step padding
+func (rosImage *RosImage) row(y int) []byte {
+	start := y * rosImage.step
+	return rosImage.data[start : start+rosImage.width*rosImage.bytesPerPixel]
+}
 
-	return color.RGBA{
-		R: rosImage.data[byteOffset+2],
-		G: rosImage.data[byteOffset+1],
-		B: rosImage.data[byteOffset],
-		A: 0,
+// toImage copies the data into a go image, the common encodings are copied a row
+// at a time and the rest are decoded per pixel
+func (rosImage *RosImage) toImage() image.Image {
+	bounds := rosImage.Bounds()
+	switch rosImage.encoding {
+	case EncodingMono8:
+		img := image.NewGray(bounds)
+		for y := 0; y < rosImage.height; y++ {
+			copy(img.Pix[y*img.Stride:], rosImage.row(y))
+		}
+		return img
+	case EncodingMono16, Encoding16UC1:
+		img := image.NewGray16(bounds)
+		for y := 0; y < rosImage.height; y++ {
+			dst := img.Pix[y*img.Stride : y*img.Stride+rosImage.width*2]
+			copy(dst, rosImage.row(y))
+			// Gray16 is big endian
+			if !rosImage.bigEndian {
+				for i := 0; i < len(dst); i += 2 {
+					dst[i], dst[i+1] = dst[i+1], dst[i]
+				}
+			}
+		}
+		return img
+	case EncodingRGBA8:
+		img := image.NewNRGBA(bounds)
+		for y := 0; y < rosImage.height; y++ {
+			copy(img.Pix[y*img.Stride:], rosImage.row(y))
+		}
+		return img
+	case EncodingRGB8, EncodingBGR8, EncodingBGRA8:
+		img := image.NewNRGBA(bounds)
+		bpp := rosImage.bytesPerPixel
+		r, b := 0, 2
+		if rosImage.encoding != EncodingRGB8 {
+			r, b = 2, 0
+		}
+		for y := 0; y < rosImage.height; y++ {
+			src := rosImage.row(y)
+			dst := img.Pix[y*img.Stride:]
+			for x := 0; x < rosImage.width; x++ {
+				s, d := src[x*bpp:], dst[x*4:]
+				d[0], d[1], d[2], d[3] = s[r], s[1], s[b], 255
+				if bpp == 4 {
+					d[3] = s[3]
+				}
+			}
+		}
+		return img
+	case Encoding32FC1:
+		img := image.NewGray16(bounds)
+		order := rosImage.byteOrder()
+		for y := 0; y < rosImage.height; y++ {
+			src := rosImage.row(y)
+			dst := img.Pix[y*img.Stride:]
+			for x := 0; x < rosImage.width; x++ {
+				meters := math.Float32frombits(order.Uint32(src[x*4:]))
+				binary.BigEndian.PutUint16(dst[x*2:], metersToMillimeters(float64(meters)))
+			}
+		}
+		return img
+	default:
+		img := image.NewRGBA(bounds)
+		draw.Draw(img, bounds, rosImage, image.Point{}, draw.Src)
+		return img
 	}
 }
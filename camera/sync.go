@@ -0,0 +1,198 @@
+package camera
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	viamcamera "go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/resource"
+)
+
+/*
+ * Named topics
+ * Every entry of topics is subscribed on its own and keeps its last few
+ * frames. Images returns one frame per topic, matched by header stamp
+ * the way the ROS approximate time synchronizer does: the newest frame of
+ * the first topic whose nearest frames on the other topics are all within
+ * sync_tolerance_ms of each other.
+ *
+ * Frames of named topics are kept as they arrive, they are only converted
+ * when returned. max_fps applies to the synchronized sets by header stamp:
+ * a set less than 1/max_fps newer than the last one is not converted and
+ * the last set is returned again. Throttling each topic on its own would
+ * keep frames that no longer match.
+ */
+
+const (
+	defaultSyncToleranceMs = 50
+	// frames kept per topic to match against, enough to cover a slower topic at 30fps
+	streamHistory = 10
+)
+
+// frame is one received image, raw images are converted on first use
+type frame struct {
+	img      image.Image
+	msg      *sensor_msgs.Image // raw image waiting to be converted
+	stamp    time.Time          // header stamp of the frame
+	received time.Time          // local time the frame arrived
+}
+
+func newFrame(img image.Image, msg *sensor_msgs.Image, stamp time.Time) *frame {
+	received := time.Now()
+	return &frame{img: img, msg: msg, stamp: captureTime(stamp, received), received: received}
+}
+
+// captureTime returns the header stamp, publishers that leave it empty get the receive time
+func captureTime(stamp, received time.Time) time.Time {
+	if stamp.IsZero() || stamp.Unix() == 0 {
+		return received
+	}
+	return stamp
+}
+
+// image converts and resizes the frame once, later calls reuse it, caller must hold rs.mu
+func (f *frame) image(targetWidth, targetHeight int) (image.Image, error) {
+	if f.img == nil {
+		img, err := convertImage(f.msg)
+		if err != nil {
+			return nil, err
+		}
+		f.img = img
+		f.msg = nil
+	}
+	if targetWidth > 0 || targetHeight > 0 {
+		f.img = resizeImage(f.img, targetWidth, targetHeight)
+	}
+	return f.img, nil
+}
+
+type namedStream struct {
+	name       string
+	topic      string
+	compressed bool
+	subscriber *goroslib.Subscriber
+	frames     []*frame // oldest first
+}
+
+func newNamedStream(cfg NamedTopicConfig) *namedStream {
+	compressed := compressedTopic(cfg.Topic)
+	if cfg.Compressed != nil {
+		compressed = *cfg.Compressed
+	}
+	return &namedStream{name: cfg.Name, topic: cfg.Topic, compressed: compressed}
+}
+
+// add keeps the frame, dropping the oldest past streamHistory, caller must hold rs.mu
+func (s *namedStream) add(f *frame) {
+	s.frames = append(s.frames, f)
+	if len(s.frames) > streamHistory {
+		s.frames = s.frames[len(s.frames)-streamHistory:]
+	}
+}
+
+func (s *namedStream) latest() *frame {
+	if len(s.frames) == 0 {
+		return nil
+	}
+	return s.frames[len(s.frames)-1]
+}
+
+// nearest returns the frame with the stamp closest to t
+func (s *namedStream) nearest(t time.Time) *frame {
+	var best *frame
+	for _, f := range s.frames {
+		if best == nil || absDuration(f.stamp.Sub(t)) < absDuration(best.stamp.Sub(t)) {
+			best = f
+		}
+	}
+	return best
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// streamCallback returns the subscriber callback for the stream's message type
+func (rs *RosMediaSource) streamCallback(s *namedStream) interface{} {
+	store := func(f *frame) {
+		if f == nil {
+			return
+		}
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		s.add(f)
+	}
+	if s.compressed {
+		return func(msg *sensor_msgs.CompressedImage) { store(rs.compressedFrame(msg)) }
+	}
+	return func(msg *sensor_msgs.Image) { store(rs.imageFrame(msg)) }
+}
+
+// syncFrames picks one frame per stream, all within tolerance of each other, preferring
+// the newest frame of the first stream
+func syncFrames(streams []*namedStream, tolerance time.Duration) ([]*frame, error) {
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("image is not ready")
+	}
+	for _, s := range streams {
+		if len(s.frames) == 0 {
+			return nil, fmt.Errorf("%s image is not ready", s.name)
+		}
+	}
+	first := streams[0].frames
+	for i := len(first) - 1; i >= 0; i-- {
+		set := []*frame{first[i]}
+		oldest, newest := first[i].stamp, first[i].stamp
+		for _, s := range streams[1:] {
+			f := s.nearest(first[i].stamp)
+			if f.stamp.Before(oldest) {
+				oldest = f.stamp
+			}
+			if f.stamp.After(newest) {
+				newest = f.stamp
+			}
+			set = append(set, f)
+		}
+		if newest.Sub(oldest) <= tolerance {
+			return set, nil
+		}
+	}
+	return nil, fmt.Errorf("no frames within %s of each other", tolerance)
+}
+
+// syncedImages returns one time synchronized image per named stream, the capture
+// time is the stamp of the first stream
+func (rs *RosMediaSource) syncedImages() ([]viamcamera.NamedImage, resource.ResponseMetadata, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	set, err := syncFrames(rs.streams, rs.syncTolerance)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	if rs.lastSet != nil && set[0].stamp.Before(rs.lastSet[0].stamp) {
+		// stamps went backwards, i.e. a restarted bag
+		rs.syncLimiter.reset()
+	}
+	if !rs.syncLimiter.allow(set[0].stamp) && rs.lastSet != nil {
+		set = rs.lastSet
+	}
+	rs.lastSet = set
+	images := make([]viamcamera.NamedImage, 0, len(set))
+	for i, f := range set {
+		if err := rs.checkAge(f); err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		img, err := f.image(rs.targetWidth, rs.targetHeight)
+		if err != nil {
+			return nil, resource.ResponseMetadata{}, err
+		}
+		images = append(images, viamcamera.NamedImage{Image: img, SourceName: rs.streams[i].name})
+	}
+	return images, resource.ResponseMetadata{CapturedAt: set[0].stamp}, nil
+}
@@ -0,0 +1,39 @@
+package camera
+
+import "time"
+
+// rateLimiter drops messages arriving faster than max_fps, a zero interval keeps every message
+type rateLimiter struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(maxFps float64) *rateLimiter {
+	if maxFps <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / maxFps)}
+}
+
+// reset starts the schedule over from the next message
+func (l *rateLimiter) reset() {
+	if l != nil {
+		l.next = time.Time{}
+	}
+}
+
+// allow reports whether a message arriving at now is kept. The next slot advances by
+// the interval rather than from now, so publisher jitter doesn't lower the rate.
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l == nil || l.interval == 0 {
+		return true
+	}
+	if now.Before(l.next) {
+		return false
+	}
+	l.next = l.next.Add(l.interval)
+	if !l.next.After(now) {
+		l.next = now.Add(l.interval)
+	}
+	return true
+}
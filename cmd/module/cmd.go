@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/brokenrobotz/viam-ros-module/base"
 	"github.com/brokenrobotz/viam-ros-module/camera"
+	"github.com/brokenrobotz/viam-ros-module/odometry"
 	"github.com/brokenrobotz/viam-ros-module/sensors"
 	"github.com/brokenrobotz/viam-ros-module/sensors/battery"
 	"github.com/brokenrobotz/viam-ros-module/viamrosnode"
@@ -35,6 +36,7 @@ func realMain() error {
 	}
 
 	err = myMod.AddModelFromRegistry(ctx, viammovementsensor.API, imu.Model)
+	err = myMod.AddModelFromRegistry(ctx, viammovementsensor.API, odometry.Model)
 	err = myMod.AddModelFromRegistry(ctx, viamsensor.API, battery.BatteryModel)
 	err = myMod.AddModelFromRegistry(ctx, viamsensor.API, battery.VoltageModel)
 	err = myMod.AddModelFromRegistry(ctx, viamsensor.API, sensors.EditionModel)
@@ -42,6 +44,8 @@ func realMain() error {
 	err = myMod.AddModelFromRegistry(ctx, viambase.API, base.RosBaseModel)
 	err = myMod.AddModelFromRegistry(ctx, viamcamera.API, camera.ROSLidarModel)
 	err = myMod.AddModelFromRegistry(ctx, viamcamera.API, camera.RosCameraModel)
+	err = myMod.AddModelFromRegistry(ctx, viamcamera.API, camera.RosDepthCameraModel)
+	err = myMod.AddModelFromRegistry(ctx, viamcamera.API, camera.RosPointCloud2Model)
 
 	err = myMod.Start(ctx)
 	defer myMod.Close(ctx)
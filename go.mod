@@ -11,6 +11,7 @@ require (
 	github.com/pkg/errors v0.9.1
 	go.viam.com/rdk v0.43.0
 	go.viam.com/test v1.1.1-0.20220913152726-5da9916c08a2
+	golang.org/x/image v0.19.0
 )
 
 require (
@@ -148,7 +149,6 @@ require (
 	goji.io v2.0.2+incompatible // indirect
 	golang.org/x/crypto v0.23.0 // indirect
 	golang.org/x/exp v0.0.0-20230725012225-302865e7556b // indirect
-	golang.org/x/image v0.19.0 // indirect
 	golang.org/x/mod v0.17.0 // indirect
 	golang.org/x/net v0.25.0 // indirect
 	golang.org/x/oauth2 v0.10.0 // indirect
@@ -0,0 +1,81 @@
+package imu
+
+import (
+	"math"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"go.viam.com/rdk/components/movementsensor"
+)
+
+/*
+ * IMU covariances
+ * Each covariance is a row major 3x3 matrix, orientation is over roll,
+ * pitch and yaw. A -1 in the first element means the value is not provided
+ * and a zero matrix means the covariance is unknown (REP-145, sensor_msgs/Imu).
+ */
+
+var (
+	orientationAxes = []string{"roll", "pitch", "yaw"}
+	vectorAxes      = []string{"x", "y", "z"}
+)
+
+// covarianceProvided reports if the field carries data at all
+func covarianceProvided(covariance [9]float64) bool {
+	return covariance[0] != -1
+}
+
+// covarianceKnown reports if the covariance can be used as an accuracy, an unknown (zero)
+// covariance is not a perfect reading
+func covarianceKnown(covariance [9]float64) bool {
+	return covarianceProvided(covariance) && covariance != [9]float64{}
+}
+
+// addStdDevs adds the square root of each non negative diagonal element keyed prefix_axis
+func addStdDevs(m map[string]float32, prefix string, axes []string, covariance [9]float64) {
+	if !covarianceKnown(covariance) {
+		return
+	}
+	for i, axis := range axes {
+		variance := covariance[i*3+i]
+		if variance < 0 {
+			continue
+		}
+		m[prefix+"_"+axis] = float32(math.Sqrt(variance))
+	}
+}
+
+// imuAccuracy fills the accuracy map with standard deviations in radians, rad/s and m/s^2,
+// compassFromOrientation also sets the compass error from the yaw variance
+func imuAccuracy(acc *movementsensor.Accuracy, msg *sensor_msgs.Imu, compassFromOrientation bool) {
+	if acc.AccuracyMap == nil {
+		acc.AccuracyMap = map[string]float32{}
+	}
+	addStdDevs(acc.AccuracyMap, "orientation", orientationAxes, msg.OrientationCovariance)
+	addStdDevs(acc.AccuracyMap, "angular_velocity", vectorAxes, msg.AngularVelocityCovariance)
+	addStdDevs(acc.AccuracyMap, "linear_acceleration", vectorAxes, msg.LinearAccelerationCovariance)
+
+	if yaw, ok := acc.AccuracyMap["orientation_yaw"]; ok && compassFromOrientation {
+		acc.CompassDegreeError = yaw * 180.0 / math.Pi
+	}
+}
+
+// covarianceReadings returns the provided covariance matrices keyed by field name
+func covarianceReadings(msg *sensor_msgs.Imu) map[string]interface{} {
+	readings := map[string]interface{}{}
+	for name, covariance := range map[string][9]float64{
+		"orientation_covariance":         msg.OrientationCovariance,
+		"angular_velocity_covariance":    msg.AngularVelocityCovariance,
+		"linear_acceleration_covariance": msg.LinearAccelerationCovariance,
+	} {
+		if !covarianceProvided(covariance) {
+			continue
+		}
+		// readings must be protobuf friendly, which does not cover []float64
+		values := make([]interface{}, len(covariance))
+		for i, c := range covariance {
+			values[i] = c
+		}
+		readings[name] = values
+	}
+	return readings
+}
@@ -0,0 +1,53 @@
+package imu
+
+import (
+	"math"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"go.viam.com/rdk/components/movementsensor"
+)
+
+func (r *RosImu) processGps(msg *sensor_msgs.NavSatFix) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gpsMsg = msg
+}
+
+func (r *RosImu) processVelocity(msg *geometry_msgs.TwistWithCovarianceStamped) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.velocityMsg = msg
+}
+
+// nmeaFix maps the NavSatFix status to the closest NMEA GGA fix quality
+func nmeaFix(status int8) int32 {
+	switch status {
+	case sensor_msgs.NavSatStatus_STATUS_FIX:
+		return 1
+	case sensor_msgs.NavSatStatus_STATUS_SBAS_FIX, sensor_msgs.NavSatStatus_STATUS_GBAS_FIX:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// hasFix reports if the latitude and longitude are valid, drivers publish 0 or NaN without a fix
+func hasFix(msg *sensor_msgs.NavSatFix) bool {
+	return msg.Status.Status != sensor_msgs.NavSatStatus_STATUS_NO_FIX
+}
+
+// gpsAccuracy fills the horizontal and vertical standard deviations (m) from the ENU position
+// covariance. NavSatFix carries no dilution of precision, so Hdop and Vdop stay unset.
+func gpsAccuracy(acc *movementsensor.Accuracy, msg *sensor_msgs.NavSatFix) {
+	acc.NmeaFix = nmeaFix(msg.Status.Status)
+	if msg.PositionCovarianceType == sensor_msgs.NavSatFix_COVARIANCE_TYPE_UNKNOWN {
+		return
+	}
+	if acc.AccuracyMap == nil {
+		acc.AccuracyMap = map[string]float32{}
+	}
+	c := msg.PositionCovariance
+	acc.AccuracyMap["position_horizontal_m"] = float32(math.Sqrt(c[0] + c[4]))
+	acc.AccuracyMap["position_vertical_m"] = float32(math.Sqrt(c[8]))
+}
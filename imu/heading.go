@@ -0,0 +1,71 @@
+package imu
+
+import (
+	"math"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Compass heading
+ * ROS uses x forward, y left and z up (REP-103), compass headings are
+ * degrees clockwise from north. The magnetometer is assumed to be level,
+ * the orientation fallback assumes the yaw is 0 facing east (REP-145).
+ */
+
+func (r *RosImu) processMag(msg *sensor_msgs.MagneticField) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mounting != nil {
+		msg = applyMagMounting(msg, *r.mounting)
+	}
+	r.magMsg = msg
+}
+
+// normalizeHeading wraps degrees into [0, 360)
+func normalizeHeading(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// magHeading returns the heading of the x axis from the horizontal magnetic field
+func magHeading(field geometry_msgs.Vector3, declination float64) float64 {
+	return normalizeHeading(math.Atan2(field.Y, field.X)*180.0/math.Pi + declination)
+}
+
+// orientationHeading converts the ENU yaw of the quaternion to a compass heading
+func orientationHeading(q geometry_msgs.Quaternion, declination float64) float64 {
+	return normalizeHeading(90 - toQuaternion(q).EulerAngles().Yaw*180.0/math.Pi + declination)
+}
+
+// compassSupported reports if there is a heading source, an imu that flags its orientation
+// as not provided has none, caller must hold r.mu
+func (r *RosImu) compassSupported() bool {
+	if r.magSubscriber != nil {
+		return true
+	}
+	return r.orientationHeading && (r.msg == nil || covarianceProvided(r.msg.OrientationCovariance))
+}
+
+// heading uses the magnetometer when subscribed, otherwise the imu orientation, caller must hold r.mu
+func (r *RosImu) heading() (float64, error) {
+	if r.magSubscriber != nil {
+		if r.magMsg == nil {
+			return 0, errors.New("magnetic field message unavailable")
+		}
+		return magHeading(r.magMsg.MagneticField, r.declination), nil
+	}
+	if r.msg == nil {
+		return 0, errors.New("message unavailable")
+	}
+	// a -1 in the first covariance element means the orientation is not provided
+	if r.msg.OrientationCovariance[0] == -1 {
+		return 0, errors.New("imu does not provide orientation")
+	}
+	return orientationHeading(r.msg.Orientation, r.declination), nil
+}
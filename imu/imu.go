@@ -5,12 +5,24 @@ package imu
  * The mapping from the ROS IMU message only supports:
  * Orientation
  * Angular Velocity
- * Linear Velocity
+ * Linear Acceleration
+ *
+ * Optional subscribers add:
+ * Position, Altitude and Accuracy (gps_topic, sensor_msgs/NavSatFix)
+ * Linear Velocity (velocity_topic, geometry_msgs/TwistWithCovarianceStamped)
+ * Compass Heading (mag_topic, sensor_msgs/MagneticField), or the yaw of
+ * the IMU orientation when orientation_heading is set, which only makes
+ * sense for IMUs whose yaw is referenced to north
+ *
+ * mounting_orientation rotates the IMU data into the robot frame
+ *
+ * Vectors stay in the ROS frames (REP-103, x forward, y left, z up), the
+ * same convention as the odometry model: the imu data and linear velocity
+ * are in the frame of their message header, not the viam base frame
  *
  * If we have other topics in ROS to produce
- * GPS, Compass Headings, etc:
- * we will need to add more subscribers and more
- * message type support
+ * other data we will need to add more subscribers
+ * and more message type support
  */
 import (
 	"context"
@@ -38,13 +50,25 @@ var DummyImuModel = resource.NewModel("brokenrobotz", "ros", "imu-dummy")
 type RosImu struct {
 	resource.Named
 
-	mu         sync.Mutex
-	primaryUri string
-	topic      string
-	node       *goroslib.Node
-	subscriber *goroslib.Subscriber
-	msg        *sensor_msgs.Imu
-	logger     logging.Logger
+	mu                 sync.Mutex
+	primaryUri         string
+	topic              string
+	gpsTopic           string
+	velocityTopic      string
+	magTopic           string
+	declination        float64 // degrees
+	orientationHeading bool
+	mounting           *geometry_msgs.Quaternion
+	node               *goroslib.Node
+	subscriber         *goroslib.Subscriber
+	gpsSubscriber      *goroslib.Subscriber
+	velocitySubscriber *goroslib.Subscriber
+	magSubscriber      *goroslib.Subscriber
+	msg                *sensor_msgs.Imu
+	gpsMsg             *sensor_msgs.NavSatFix
+	velocityMsg        *geometry_msgs.TwistWithCovarianceStamped
+	magMsg             *sensor_msgs.MagneticField
+	logger             logging.Logger
 }
 
 func init() {
@@ -112,6 +136,21 @@ func (r *RosImu) Reconfigure(
 	defer r.mu.Unlock()
 	r.primaryUri = conf.Attributes.String("primary_uri")
 	r.topic = conf.Attributes.String("topic")
+	r.gpsTopic = conf.Attributes.String("gps_topic")
+	r.velocityTopic = conf.Attributes.String("velocity_topic")
+	r.magTopic = conf.Attributes.String("mag_topic")
+	r.declination = conf.Attributes.Float64("declination_deg", 0)
+	r.orientationHeading = conf.Attributes.Bool("orientation_heading", false)
+
+	cfg, err := resource.NativeConfig[*RosImuConfig](conf)
+	if err != nil {
+		return err
+	}
+	r.mounting = nil
+	if cfg.MountingOrientation != nil {
+		q := cfg.MountingOrientation.quaternion()
+		r.mounting = &q
+	}
 
 	if len(strings.TrimSpace(r.primaryUri)) == 0 {
 		return errors.New("ROS primary uri must be set to hostname:port")
@@ -121,11 +160,13 @@ func (r *RosImu) Reconfigure(
 		return errors.New("ROS topic must be set to valid imu topic")
 	}
 
-	if r.subscriber != nil {
-		r.subscriber.Close()
-	}
+	r.closeSubscribers()
+	r.gpsMsg = nil
+	r.velocityMsg = nil
+	r.magMsg = nil
+	// earlier messages are in the old mounting frame
+	r.msg = nil
 
-	var err error
 	r.node, err = viamrosnode.GetInstance(r.primaryUri)
 	if err != nil {
 		return err
@@ -140,84 +181,165 @@ func (r *RosImu) Reconfigure(
 		return err
 	}
 
+	if len(strings.TrimSpace(r.gpsTopic)) > 0 {
+		r.gpsSubscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+			Node:     r.node,
+			Topic:    r.gpsTopic,
+			Callback: r.processGps,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(strings.TrimSpace(r.velocityTopic)) > 0 {
+		r.velocitySubscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+			Node:     r.node,
+			Topic:    r.velocityTopic,
+			Callback: r.processVelocity,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(strings.TrimSpace(r.magTopic)) > 0 {
+		r.magSubscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+			Node:     r.node,
+			Topic:    r.magTopic,
+			Callback: r.processMag,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// closeSubscribers closes all subscribers, caller must hold r.mu
+func (r *RosImu) closeSubscribers() {
+	for _, s := range []*goroslib.Subscriber{r.subscriber, r.gpsSubscriber, r.velocitySubscriber, r.magSubscriber} {
+		if s != nil {
+			s.Close()
+		}
+	}
+	r.subscriber = nil
+	r.gpsSubscriber = nil
+	r.velocitySubscriber = nil
+	r.magSubscriber = nil
+}
+
 func (r *RosImu) processMessage(msg *sensor_msgs.Imu) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.mounting != nil {
+		msg = applyMounting(msg, *r.mounting)
+	}
 	r.msg = msg
 }
 
+// latest returns the last imu message, messages are replaced rather than modified
+// so the snapshot can be read without holding the lock
+func (r *RosImu) latest() (*sensor_msgs.Imu, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.msg == nil {
+		return nil, errors.New("message unavailable")
+	}
+	return r.msg, nil
+}
+
 func (r *RosImu) Position(
 	_ context.Context,
 	_ map[string]interface{},
 ) (*geo.Point, float64, error) {
-	return geo.NewPoint(0, 0), 0, movementsensor.ErrMethodUnimplementedPosition
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.gpsSubscriber == nil {
+		return geo.NewPoint(0, 0), 0, movementsensor.ErrMethodUnimplementedPosition
+	}
+	if r.gpsMsg == nil {
+		return geo.NewPoint(0, 0), 0, errors.New("gps message unavailable")
+	}
+	if !hasFix(r.gpsMsg) {
+		return geo.NewPoint(0, 0), 0, errors.New("gps has no fix")
+	}
+	return geo.NewPoint(r.gpsMsg.Latitude, r.gpsMsg.Longitude), r.gpsMsg.Altitude, nil
 }
 
 func (r *RosImu) LinearVelocity(
 	_ context.Context,
 	_ map[string]interface{},
 ) (r3.Vector, error) {
-	return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearVelocity
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.velocitySubscriber == nil {
+		return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearVelocity
+	}
+	if r.velocityMsg == nil {
+		return r3.Vector{}, errors.New("velocity message unavailable")
+	}
+	return toVector(r.velocityMsg.Twist.Twist.Linear), nil
 }
 
 func (r *RosImu) AngularVelocity(
 	_ context.Context,
 	_ map[string]interface{},
 ) (spatialmath.AngularVelocity, error) {
-	if r.msg == nil {
-		return spatialmath.AngularVelocity{}, errors.New("message unavailable")
-	}
-	av := spatialmath.AngularVelocity{
-		X: r.msg.AngularVelocity.X,
-		Y: r.msg.AngularVelocity.Y,
-		Z: r.msg.AngularVelocity.Z,
+	msg, err := r.latest()
+	if err != nil {
+		return spatialmath.AngularVelocity{}, err
 	}
-	return av, nil
+	return toAngularVelocity(msg.AngularVelocity), nil
 }
 
 func (r *RosImu) LinearAcceleration(
 	_ context.Context,
 	_ map[string]interface{},
 ) (r3.Vector, error) {
-	if r.msg == nil {
-		return r3.Vector{}, errors.New("message unavailable")
-	}
-	la := r3.Vector{
-		X: r.msg.LinearAcceleration.X,
-		Y: r.msg.LinearAcceleration.Y,
-		Z: r.msg.LinearAcceleration.Z,
+	msg, err := r.latest()
+	if err != nil {
+		return r3.Vector{}, err
 	}
-	return la, nil
+	return toVector(msg.LinearAcceleration), nil
 }
 
 func (r *RosImu) CompassHeading(
 	_ context.Context,
 	_ map[string]interface{},
 ) (float64, error) {
-	return 0, movementsensor.ErrMethodUnimplementedCompassHeading
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.compassSupported() {
+		return 0, movementsensor.ErrMethodUnimplementedCompassHeading
+	}
+	return r.heading()
 }
 
 func (r *RosImu) Orientation(
 	_ context.Context,
 	_ map[string]interface{},
 ) (spatialmath.Orientation, error) {
-	q := r.msg.Orientation
-	return &spatialmath.Quaternion{Real: q.W, Imag: q.X, Jmag: q.Y, Kmag: q.Z}, nil
+	msg, err := r.latest()
+	if err != nil {
+		return nil, err
+	}
+	return toQuaternion(msg.Orientation), nil
 }
 
 func (r *RosImu) Properties(
 	_ context.Context,
 	_ map[string]interface{},
 ) (*movementsensor.Properties, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return &movementsensor.Properties{
-		PositionSupported:           false,
+		PositionSupported:           r.gpsSubscriber != nil,
 		AngularVelocitySupported:    true,
-		CompassHeadingSupported:     false,
+		CompassHeadingSupported:     r.compassSupported(),
 		OrientationSupported:        true,
-		LinearVelocitySupported:     false,
+		LinearVelocitySupported:     r.velocitySubscriber != nil,
 		LinearAccelerationSupported: true,
 	}, nil
 }
@@ -226,26 +348,53 @@ func (r *RosImu) Readings(
 	ctx context.Context,
 	extra map[string]interface{},
 ) (map[string]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.msg == nil {
 		return nil, errors.New("message unavailable")
 	}
 
-	return nil, nil
+	readings := imuReadings(r.msg)
+	if r.gpsMsg != nil && hasFix(r.gpsMsg) {
+		readings["position"] = geo.NewPoint(r.gpsMsg.Latitude, r.gpsMsg.Longitude)
+		readings["altitude"] = r.gpsMsg.Altitude
+	}
+	if r.velocityMsg != nil {
+		readings["linear_velocity"] = toVector(r.velocityMsg.Twist.Twist.Linear)
+	}
+	if r.compassSupported() {
+		if heading, err := r.heading(); err == nil {
+			readings["compass"] = heading
+		}
+	}
+	return readings, nil
 }
 
+// Accuracy reports the standard deviations from the imu covariances, and the gps fix
+// and horizontal/vertical standard deviations when gps_topic is set
 func (r *RosImu) Accuracy(
 	_ context.Context,
 	_ map[string]interface{},
 ) (*movementsensor.Accuracy, error) {
-	return nil, movementsensor.ErrMethodUnimplementedAccuracy
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.msg == nil && r.gpsMsg == nil {
+		return nil, errors.New("message unavailable")
+	}
+	acc := movementsensor.UnimplementedOptionalAccuracies()
+	if r.msg != nil {
+		imuAccuracy(acc, r.msg, r.magSubscriber == nil && r.orientationHeading)
+	}
+	if r.gpsMsg != nil {
+		gpsAccuracy(acc, r.gpsMsg)
+	}
+	return acc, nil
 }
 
 func (r *RosImu) Close(_ context.Context) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if r.subscriber != nil {
-		r.subscriber.Close()
-	}
+	r.closeSubscribers()
 	return nil
 }
 
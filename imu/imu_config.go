@@ -6,6 +6,17 @@ type RosImuConfig struct {
 	NodeName   string `json:"node_name"`
 	PrimaryUri string `json:"primary_uri"`
 	Topic      string `json:"topic"`
+	// optional sensor_msgs/NavSatFix and geometry_msgs/TwistWithCovarianceStamped topics
+	GpsTopic      string `json:"gps_topic"`
+	VelocityTopic string `json:"velocity_topic"`
+	// optional sensor_msgs/MagneticField topic for the compass heading
+	MagTopic    string  `json:"mag_topic"`
+	Declination float64 `json:"declination_deg"`
+	// use the imu orientation yaw for the compass heading when there is no mag_topic,
+	// only for imus whose yaw is referenced to north (not relative to startup)
+	OrientationHeading bool `json:"orientation_heading"`
+	// optional rotation of the imu in the robot frame
+	MountingOrientation *MountingConfig `json:"mounting_orientation,omitempty"`
 }
 
 func (cfg *RosImuConfig) Validate(path string) ([]string, error) {
@@ -18,5 +29,18 @@ func (cfg *RosImuConfig) Validate(path string) ([]string, error) {
 		return nil, fmt.Errorf(`expected "RosTopic" attribute for sensor %q`, path)
 	}
 
+	if cfg.Declination < -180 || cfg.Declination > 180 {
+		return nil, fmt.Errorf(`"declination_deg" must be between -180 and 180 for sensor %q`, path)
+	}
+
+	if m := cfg.MountingOrientation; m != nil {
+		if m.isQuaternion() && m.isRPY() {
+			return nil, fmt.Errorf(`"mounting_orientation" must be either a quaternion or roll/pitch/yaw for sensor %q`, path)
+		}
+		if !m.isQuaternion() && !m.isRPY() {
+			return nil, fmt.Errorf(`"mounting_orientation" must not be empty for sensor %q`, path)
+		}
+	}
+
 	return nil, nil
 }
@@ -1,8 +1,16 @@
 package imu
 
 import (
+	"context"
+	"math"
 	"testing"
 
+	"github.com/bluenviron/goroslib/v2"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/protoutils"
+
 	"go.viam.com/test"
 )
 
@@ -12,3 +20,183 @@ func TestImuConvert(t *testing.T) {
 	test.That(t, err, test.ShouldBeNil)
 	test.That(t, len(msgs), test.ShouldBeGreaterThan, 1)
 }
+
+func TestGpsAccuracy(t *testing.T) {
+	msg := &sensor_msgs.NavSatFix{
+		Status:                 sensor_msgs.NavSatStatus{Status: sensor_msgs.NavSatStatus_STATUS_GBAS_FIX},
+		PositionCovariance:     [9]float64{9, 0, 0, 0, 16, 0, 0, 0, 4},
+		PositionCovarianceType: sensor_msgs.NavSatFix_COVARIANCE_TYPE_DIAGONAL_KNOWN,
+	}
+	acc := movementsensor.UnimplementedOptionalAccuracies()
+	gpsAccuracy(acc, msg)
+	test.That(t, acc.NmeaFix, test.ShouldEqual, 2)
+	test.That(t, acc.AccuracyMap["position_horizontal_m"], test.ShouldAlmostEqual, 5)
+	test.That(t, acc.AccuracyMap["position_vertical_m"], test.ShouldAlmostEqual, 2)
+	// the covariance is not a dilution of precision
+	test.That(t, math.IsNaN(float64(acc.Hdop)), test.ShouldBeTrue)
+	test.That(t, math.IsNaN(float64(acc.Vdop)), test.ShouldBeTrue)
+
+	// unknown covariance leaves the accuracy unset
+	msg.PositionCovarianceType = sensor_msgs.NavSatFix_COVARIANCE_TYPE_UNKNOWN
+	msg.Status.Status = sensor_msgs.NavSatStatus_STATUS_NO_FIX
+	acc = movementsensor.UnimplementedOptionalAccuracies()
+	gpsAccuracy(acc, msg)
+	test.That(t, acc.NmeaFix, test.ShouldEqual, 0)
+	test.That(t, acc.AccuracyMap, test.ShouldNotContainKey, "position_horizontal_m")
+
+	// without a fix there is no position
+	r := &RosImu{gpsSubscriber: &goroslib.Subscriber{}, gpsMsg: msg}
+	_, _, err := r.Position(context.Background(), nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	msg.Status.Status = sensor_msgs.NavSatStatus_STATUS_FIX
+	_, _, err = r.Position(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestCompassHeading(t *testing.T) {
+	// facing north the field points along x, facing east it points left (+y)
+	test.That(t, magHeading(geometry_msgs.Vector3{X: 1}, 0), test.ShouldAlmostEqual, 0)
+	test.That(t, magHeading(geometry_msgs.Vector3{Y: 1}, 0), test.ShouldAlmostEqual, 90)
+	test.That(t, magHeading(geometry_msgs.Vector3{Y: -1}, 0), test.ShouldAlmostEqual, 270)
+	test.That(t, magHeading(geometry_msgs.Vector3{X: 1}, -10), test.ShouldAlmostEqual, 350)
+
+	// yaw 0 faces east, yaw 90 faces north
+	test.That(t, orientationHeading(geometry_msgs.Quaternion{W: 1}, 0), test.ShouldAlmostEqual, 90)
+	s := math.Sqrt(0.5)
+	test.That(t, orientationHeading(geometry_msgs.Quaternion{W: s, Z: s}, 0), test.ShouldAlmostEqual, 0)
+	test.That(t, orientationHeading(geometry_msgs.Quaternion{W: s, Z: -s}, 5), test.ShouldAlmostEqual, 185)
+}
+
+func TestImuAccuracy(t *testing.T) {
+	msg := &sensor_msgs.Imu{
+		OrientationCovariance:        [9]float64{-1},
+		AngularVelocityCovariance:    [9]float64{0.04, 0, 0, 0, 0.09, 0, 0, 0, -1},
+		LinearAccelerationCovariance: [9]float64{0.25, 0, 0, 0, 0.25, 0, 0, 0, 0.25},
+	}
+	acc := movementsensor.UnimplementedOptionalAccuracies()
+	imuAccuracy(acc, msg, true)
+
+	// orientation is not provided, negative variances are skipped
+	_, ok := acc.AccuracyMap["orientation_yaw"]
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = acc.AccuracyMap["angular_velocity_z"]
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, acc.AccuracyMap["angular_velocity_x"], test.ShouldAlmostEqual, 0.2, 1e-6)
+	test.That(t, acc.AccuracyMap["angular_velocity_y"], test.ShouldAlmostEqual, 0.3, 1e-6)
+	test.That(t, acc.AccuracyMap["linear_acceleration_z"], test.ShouldAlmostEqual, 0.5, 1e-6)
+	test.That(t, math.IsNaN(float64(acc.CompassDegreeError)), test.ShouldBeTrue)
+
+	msg.OrientationCovariance = [9]float64{0, 0, 0, 0, 0, 0, 0, 0, 0.01}
+	imuAccuracy(acc, msg, true)
+	test.That(t, acc.CompassDegreeError, test.ShouldAlmostEqual, 0.1*180.0/math.Pi, 1e-4)
+
+	// the bag imu leaves the orientation covariance unknown (all zero)
+	msgs, err := loadMessages("data/imu.bag")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, msgs[0].OrientationCovariance, test.ShouldResemble, [9]float64{})
+	acc = movementsensor.UnimplementedOptionalAccuracies()
+	imuAccuracy(acc, &msgs[0], true)
+	test.That(t, acc.AccuracyMap, test.ShouldNotContainKey, "orientation_yaw")
+	test.That(t, math.IsNaN(float64(acc.CompassDegreeError)), test.ShouldBeTrue)
+
+	readings := covarianceReadings(msg)
+	test.That(t, readings, test.ShouldContainKey, "orientation_covariance")
+	test.That(t, readings, test.ShouldContainKey, "linear_acceleration_covariance")
+}
+
+func TestImuReadings(t *testing.T) {
+	r := &RosImu{}
+	_, err := r.Orientation(context.Background(), nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = r.Readings(context.Background(), nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	msgs, err := loadMessages("data/imu.bag")
+	test.That(t, err, test.ShouldBeNil)
+	r.msg = &msgs[0]
+	r.orientationHeading = true
+	readings, err := r.Readings(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	for _, key := range []string{
+		"orientation", "orientation_euler", "angular_velocity", "linear_acceleration",
+		"orientation_covariance", "stamp", "frame_id", "compass",
+	} {
+		test.That(t, readings, test.ShouldContainKey, key)
+	}
+
+	// angular velocity is reported in deg/s like the odometry sensor
+	av, err := r.AngularVelocity(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, av.Z, test.ShouldAlmostEqual, msgs[0].AngularVelocity.Z*180.0/math.Pi)
+	test.That(t, readings["angular_velocity"], test.ShouldResemble, av)
+
+	// readings are sent over grpc and captured as protobuf structs
+	_, err = protoutils.ReadingGoToProto(readings)
+	test.That(t, err, test.ShouldBeNil)
+
+	// orientation flagged as not provided is left out
+	msg := msgs[0]
+	msg.OrientationCovariance[0] = -1
+	readings = imuReadings(&msg)
+	test.That(t, readings, test.ShouldNotContainKey, "orientation")
+	test.That(t, readings, test.ShouldNotContainKey, "orientation_covariance")
+
+	// and gives no compass heading
+	r.msg = &msg
+	props, err := r.Properties(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.CompassHeadingSupported, test.ShouldBeFalse)
+	_, err = r.CompassHeading(context.Background(), nil)
+	test.That(t, err, test.ShouldEqual, movementsensor.ErrMethodUnimplementedCompassHeading)
+
+	// the orientation heading is opt in
+	r = &RosImu{msg: &msgs[0]}
+	props, err = r.Properties(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.CompassHeadingSupported, test.ShouldBeFalse)
+}
+
+func TestMounting(t *testing.T) {
+	// imu rotated 90 degrees left, its x axis points along the robot y axis
+	mounting := (&MountingConfig{Yaw: 90}).quaternion()
+	msg := &sensor_msgs.Imu{
+		Orientation:                  geometry_msgs.Quaternion{W: 1},
+		AngularVelocity:              geometry_msgs.Vector3{X: 1, Z: 2},
+		LinearAcceleration:           geometry_msgs.Vector3{X: 1},
+		LinearAccelerationCovariance: [9]float64{4, 0, 0, 0, 1, 0, 0, 0, 1},
+		AngularVelocityCovariance:    [9]float64{-1},
+	}
+	out := applyMounting(msg, mounting)
+
+	test.That(t, out.LinearAcceleration.X, test.ShouldAlmostEqual, 0)
+	test.That(t, out.LinearAcceleration.Y, test.ShouldAlmostEqual, 1)
+	test.That(t, out.AngularVelocity.Y, test.ShouldAlmostEqual, 1)
+	test.That(t, out.AngularVelocity.Z, test.ShouldAlmostEqual, 2)
+	test.That(t, out.LinearAccelerationCovariance[0], test.ShouldAlmostEqual, 1)
+	test.That(t, out.LinearAccelerationCovariance[4], test.ShouldAlmostEqual, 4)
+	test.That(t, out.AngularVelocityCovariance[0], test.ShouldEqual, -1)
+
+	// the imu reads yaw 0 so the robot faces -90 degrees
+	yaw := toQuaternion(out.Orientation).EulerAngles().Yaw
+	test.That(t, yaw, test.ShouldAlmostEqual, -math.Pi/2)
+	// the original message is untouched
+	test.That(t, msg.LinearAcceleration.X, test.ShouldEqual, 1)
+
+	// the magnetometer is rotated too, north along the imu x axis is to the robot's left
+	r := &RosImu{mounting: &mounting, magSubscriber: &goroslib.Subscriber{}}
+	r.processMag(&sensor_msgs.MagneticField{MagneticField: geometry_msgs.Vector3{X: 1}})
+	heading, err := r.CompassHeading(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, heading, test.ShouldAlmostEqual, 90)
+
+	// the quaternion form is the same rotation
+	s := math.Sqrt(0.5)
+	q := (&MountingConfig{Z: s, W: s}).quaternion()
+	test.That(t, q.Z, test.ShouldAlmostEqual, mounting.Z)
+	test.That(t, q.W, test.ShouldAlmostEqual, mounting.W)
+
+	// roll follows the URDF fixed axis convention, y rotates to z
+	roll := applyMounting(&sensor_msgs.Imu{LinearAcceleration: geometry_msgs.Vector3{Y: 1}},
+		(&MountingConfig{Roll: 90}).quaternion())
+	test.That(t, roll.LinearAcceleration.Z, test.ShouldAlmostEqual, 1)
+}
@@ -0,0 +1,130 @@
+package imu
+
+import (
+	"math"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"go.viam.com/rdk/spatialmath"
+)
+
+/*
+ * Mounting orientation
+ * The orientation of the imu frame in the robot frame, either as a quaternion
+ * or as fixed axis roll, pitch and yaw in degrees (the URDF convention).
+ * Angular velocity, linear acceleration and their covariances are rotated into
+ * the robot frame, the orientation becomes the orientation of the robot frame.
+ */
+
+// MountingConfig is the rotation from the robot frame to the imu frame
+type MountingConfig struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Z     float64 `json:"z"`
+	W     float64 `json:"w"`
+	Roll  float64 `json:"roll_deg"`
+	Pitch float64 `json:"pitch_deg"`
+	Yaw   float64 `json:"yaw_deg"`
+}
+
+func (m *MountingConfig) isQuaternion() bool {
+	return m.X != 0 || m.Y != 0 || m.Z != 0 || m.W != 0
+}
+
+func (m *MountingConfig) isRPY() bool {
+	return m.Roll != 0 || m.Pitch != 0 || m.Yaw != 0
+}
+
+// quaternion returns the normalized mounting rotation
+func (m *MountingConfig) quaternion() geometry_msgs.Quaternion {
+	if m.isQuaternion() {
+		n := math.Sqrt(m.X*m.X + m.Y*m.Y + m.Z*m.Z + m.W*m.W)
+		return geometry_msgs.Quaternion{X: m.X / n, Y: m.Y / n, Z: m.Z / n, W: m.W / n}
+	}
+	q := (&spatialmath.EulerAngles{
+		Roll:  m.Roll * math.Pi / 180.0,
+		Pitch: m.Pitch * math.Pi / 180.0,
+		Yaw:   m.Yaw * math.Pi / 180.0,
+	}).Quaternion()
+	return geometry_msgs.Quaternion{X: q.Imag, Y: q.Jmag, Z: q.Kmag, W: q.Real}
+}
+
+// quatMul is the hamilton product a * b
+func quatMul(a, b geometry_msgs.Quaternion) geometry_msgs.Quaternion {
+	return geometry_msgs.Quaternion{
+		W: a.W*b.W - a.X*b.X - a.Y*b.Y - a.Z*b.Z,
+		X: a.W*b.X + a.X*b.W + a.Y*b.Z - a.Z*b.Y,
+		Y: a.W*b.Y - a.X*b.Z + a.Y*b.W + a.Z*b.X,
+		Z: a.W*b.Z + a.X*b.Y - a.Y*b.X + a.Z*b.W,
+	}
+}
+
+func quatConj(q geometry_msgs.Quaternion) geometry_msgs.Quaternion {
+	return geometry_msgs.Quaternion{X: -q.X, Y: -q.Y, Z: -q.Z, W: q.W}
+}
+
+// rotationMatrix returns the row major matrix that rotates vectors by the unit quaternion
+func rotationMatrix(q geometry_msgs.Quaternion) [9]float64 {
+	w, x, y, z := q.W, q.X, q.Y, q.Z
+	return [9]float64{
+		1 - 2*y*y - 2*z*z, 2*x*y - 2*w*z, 2*x*z + 2*w*y,
+		2*x*y + 2*w*z, 1 - 2*x*x - 2*z*z, 2*y*z - 2*w*x,
+		2*x*z - 2*w*y, 2*y*z + 2*w*x, 1 - 2*x*x - 2*y*y,
+	}
+}
+
+func rotateVector(rm [9]float64, v geometry_msgs.Vector3) geometry_msgs.Vector3 {
+	return geometry_msgs.Vector3{
+		X: rm[0]*v.X + rm[1]*v.Y + rm[2]*v.Z,
+		Y: rm[3]*v.X + rm[4]*v.Y + rm[5]*v.Z,
+		Z: rm[6]*v.X + rm[7]*v.Y + rm[8]*v.Z,
+	}
+}
+
+// rotateCovariance returns R C R^T, unprovided covariances are left as is
+func rotateCovariance(rm [9]float64, c [9]float64) [9]float64 {
+	if !covarianceProvided(c) {
+		return c
+	}
+	var rc, out [9]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				rc[i*3+j] += rm[i*3+k] * c[k*3+j]
+			}
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				out[i*3+j] += rc[i*3+k] * rm[j*3+k]
+			}
+		}
+	}
+	return out
+}
+
+// applyMounting returns a copy of the message in the robot frame, mounting is the
+// orientation of the imu in the robot frame
+func applyMounting(msg *sensor_msgs.Imu, mounting geometry_msgs.Quaternion) *sensor_msgs.Imu {
+	rm := rotationMatrix(mounting)
+	out := *msg
+	if covarianceProvided(msg.OrientationCovariance) {
+		out.Orientation = quatMul(msg.Orientation, quatConj(mounting))
+	}
+	out.AngularVelocity = rotateVector(rm, msg.AngularVelocity)
+	out.AngularVelocityCovariance = rotateCovariance(rm, msg.AngularVelocityCovariance)
+	out.LinearAcceleration = rotateVector(rm, msg.LinearAcceleration)
+	out.LinearAccelerationCovariance = rotateCovariance(rm, msg.LinearAccelerationCovariance)
+	return &out
+}
+
+// applyMagMounting returns a copy of the magnetic field in the robot frame, so the
+// compass heading is that of the robot rather than of the imu
+func applyMagMounting(msg *sensor_msgs.MagneticField, mounting geometry_msgs.Quaternion) *sensor_msgs.MagneticField {
+	rm := rotationMatrix(mounting)
+	out := *msg
+	out.MagneticField = rotateVector(rm, msg.MagneticField)
+	out.MagneticFieldCovariance = rotateCovariance(rm, msg.MagneticFieldCovariance)
+	return &out
+}
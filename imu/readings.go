@@ -0,0 +1,47 @@
+package imu
+
+import (
+	"math"
+	"time"
+
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/geometry_msgs"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/sensor_msgs"
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/spatialmath"
+)
+
+func toQuaternion(q geometry_msgs.Quaternion) *spatialmath.Quaternion {
+	return &spatialmath.Quaternion{Real: q.W, Imag: q.X, Jmag: q.Y, Kmag: q.Z}
+}
+
+// toAngularVelocity converts ROS rad/s to the deg/s viam expects
+func toAngularVelocity(v geometry_msgs.Vector3) spatialmath.AngularVelocity {
+	return spatialmath.AngularVelocity{
+		X: v.X * 180.0 / math.Pi,
+		Y: v.Y * 180.0 / math.Pi,
+		Z: v.Z * 180.0 / math.Pi,
+	}
+}
+
+func toVector(v geometry_msgs.Vector3) r3.Vector {
+	return r3.Vector{X: v.X, Y: v.Y, Z: v.Z}
+}
+
+// imuReadings maps every field of the message, fields flagged as not provided are left out
+func imuReadings(msg *sensor_msgs.Imu) map[string]interface{} {
+	readings := covarianceReadings(msg)
+	if covarianceProvided(msg.OrientationCovariance) {
+		o := toQuaternion(msg.Orientation)
+		readings["orientation"] = o
+		readings["orientation_euler"] = o.EulerAngles()
+	}
+	if covarianceProvided(msg.AngularVelocityCovariance) {
+		readings["angular_velocity"] = toAngularVelocity(msg.AngularVelocity)
+	}
+	if covarianceProvided(msg.LinearAccelerationCovariance) {
+		readings["linear_acceleration"] = toVector(msg.LinearAcceleration)
+	}
+	readings["stamp"] = msg.Header.Stamp.Format(time.RFC3339Nano)
+	readings["frame_id"] = msg.Header.FrameId
+	return readings
+}
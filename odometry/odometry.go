@@ -0,0 +1,281 @@
+package odometry
+
+/*
+ * RosOdometry
+ * Maps the ROS nav_msgs/Odometry message to a viam movement sensor:
+ * Position (odom pose as a geo point around a configured origin)
+ * Orientation
+ * Linear Velocity
+ * Angular Velocity
+ * Accuracy (pose covariance)
+ *
+ * Orientation and velocities stay in the ROS frames (REP-103, x forward,
+ * y left, z up): the orientation of the child frame in the odom frame and
+ * the velocities in the child frame, like the imu model. The odom frame
+ * x axis points at origin_heading_deg (east by default)
+ */
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+
+	"github.com/bluenviron/goroslib/v2"
+	"github.com/bluenviron/goroslib/v2/pkg/msgs/nav_msgs"
+	"github.com/brokenrobotz/viam-ros-module/viamrosnode"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+)
+
+var Model = resource.NewModel("brokenrobotz", "ros", "odometry")
+
+// pose covariance is row major 6x6 over x, y, z, roll, pitch, yaw
+var poseCovarianceNames = []string{"x", "y", "z", "roll", "pitch", "yaw"}
+
+type RosOdometry struct {
+	resource.Named
+
+	mu            sync.Mutex
+	primaryUri    string
+	topic         string
+	origin        *geo.Point
+	originHeading float64 // degrees
+	node          *goroslib.Node
+	subscriber    *goroslib.Subscriber
+	msg           *nav_msgs.Odometry
+	logger        logging.Logger
+}
+
+func init() {
+	resource.RegisterComponent(
+		movementsensor.API,
+		Model,
+		resource.Registration[movementsensor.MovementSensor, *RosOdometryConfig]{
+			Constructor: NewRosOdometry,
+		},
+	)
+}
+
+func NewRosOdometry(
+	ctx context.Context,
+	deps resource.Dependencies,
+	conf resource.Config,
+	logger logging.Logger,
+) (movementsensor.MovementSensor, error) {
+	o := &RosOdometry{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+	}
+
+	if err := o.Reconfigure(ctx, deps, conf); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (o *RosOdometry) Reconfigure(
+	_ context.Context,
+	_ resource.Dependencies,
+	conf resource.Config,
+) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.primaryUri = conf.Attributes.String("primary_uri")
+	o.topic = conf.Attributes.String("topic")
+	o.origin = geo.NewPoint(
+		conf.Attributes.Float64("origin_lat", 0),
+		conf.Attributes.Float64("origin_lng", 0),
+	)
+	o.originHeading = conf.Attributes.Float64("origin_heading_deg", 90)
+
+	if len(strings.TrimSpace(o.primaryUri)) == 0 {
+		return errors.New("ROS primary uri must be set to hostname:port")
+	}
+
+	if len(strings.TrimSpace(o.topic)) == 0 {
+		return errors.New("ROS topic must be set to valid odometry topic")
+	}
+
+	if o.subscriber != nil {
+		o.subscriber.Close()
+	}
+
+	var err error
+	o.node, err = viamrosnode.GetInstance(o.primaryUri)
+	if err != nil {
+		return err
+	}
+
+	o.subscriber, err = goroslib.NewSubscriber(goroslib.SubscriberConf{
+		Node:     o.node,
+		Topic:    o.topic,
+		Callback: o.processMessage,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (o *RosOdometry) processMessage(msg *nav_msgs.Odometry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.msg = msg
+}
+
+// latest returns the last message, origin and origin heading
+func (o *RosOdometry) latest() (*nav_msgs.Odometry, *geo.Point, float64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.msg == nil {
+		return nil, nil, 0, errors.New("message unavailable")
+	}
+	return o.msg, o.origin, o.originHeading, nil
+}
+
+func (o *RosOdometry) Position(
+	_ context.Context,
+	_ map[string]interface{},
+) (*geo.Point, float64, error) {
+	msg, origin, heading, err := o.latest()
+	if err != nil {
+		return geo.NewPoint(0, 0), 0, err
+	}
+	p := msg.Pose.Pose.Position
+	return poseToGeo(origin, heading, p.X, p.Y), p.Z, nil
+}
+
+func (o *RosOdometry) LinearVelocity(
+	_ context.Context,
+	_ map[string]interface{},
+) (r3.Vector, error) {
+	msg, _, _, err := o.latest()
+	if err != nil {
+		return r3.Vector{}, err
+	}
+	l := msg.Twist.Twist.Linear
+	return r3.Vector{X: l.X, Y: l.Y, Z: l.Z}, nil
+}
+
+func (o *RosOdometry) AngularVelocity(
+	_ context.Context,
+	_ map[string]interface{},
+) (spatialmath.AngularVelocity, error) {
+	msg, _, _, err := o.latest()
+	if err != nil {
+		return spatialmath.AngularVelocity{}, err
+	}
+	// viam expects degrees per second
+	a := msg.Twist.Twist.Angular
+	return spatialmath.AngularVelocity{
+		X: a.X * 180.0 / math.Pi,
+		Y: a.Y * 180.0 / math.Pi,
+		Z: a.Z * 180.0 / math.Pi,
+	}, nil
+}
+
+func (o *RosOdometry) LinearAcceleration(
+	_ context.Context,
+	_ map[string]interface{},
+) (r3.Vector, error) {
+	return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearAcceleration
+}
+
+func (o *RosOdometry) CompassHeading(
+	_ context.Context,
+	_ map[string]interface{},
+) (float64, error) {
+	return 0, movementsensor.ErrMethodUnimplementedCompassHeading
+}
+
+func (o *RosOdometry) Orientation(
+	_ context.Context,
+	_ map[string]interface{},
+) (spatialmath.Orientation, error) {
+	msg, _, _, err := o.latest()
+	if err != nil {
+		return nil, err
+	}
+	q := msg.Pose.Pose.Orientation
+	return &spatialmath.Quaternion{Real: q.W, Imag: q.X, Jmag: q.Y, Kmag: q.Z}, nil
+}
+
+func (o *RosOdometry) Properties(
+	_ context.Context,
+	_ map[string]interface{},
+) (*movementsensor.Properties, error) {
+	return &movementsensor.Properties{
+		PositionSupported:           true,
+		AngularVelocitySupported:    true,
+		CompassHeadingSupported:     false,
+		OrientationSupported:        true,
+		LinearVelocitySupported:     true,
+		LinearAccelerationSupported: false,
+	}, nil
+}
+
+func (o *RosOdometry) Readings(
+	ctx context.Context,
+	extra map[string]interface{},
+) (map[string]interface{}, error) {
+	return movementsensor.DefaultAPIReadings(ctx, o, extra)
+}
+
+// Accuracy reports the standard deviation of each pose axis from the covariance diagonal
+func (o *RosOdometry) Accuracy(
+	_ context.Context,
+	_ map[string]interface{},
+) (*movementsensor.Accuracy, error) {
+	msg, _, _, err := o.latest()
+	if err != nil {
+		return nil, err
+	}
+	return poseAccuracy(msg.Pose.Covariance), nil
+}
+
+func (o *RosOdometry) Close(_ context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.subscriber != nil {
+		o.subscriber.Close()
+	}
+	return nil
+}
+
+// poseToGeo converts an odom frame position in meters to a geo point, heading is the
+// compass heading in degrees of the odom x axis
+func poseToGeo(origin *geo.Point, heading, x, y float64) *geo.Point {
+	distance := math.Hypot(x, y)
+	if distance == 0 {
+		return geo.NewPoint(origin.Lat(), origin.Lng())
+	}
+	// odom angles are counter-clockwise from x, compass bearings are clockwise from north
+	bearing := heading - math.Atan2(y, x)*180.0/math.Pi
+	return origin.PointAtDistanceAndBearing(distance/1000.0, bearing)
+}
+
+// poseAccuracy maps the pose covariance diagonal to standard deviations, negative
+// (unknown) variances are skipped and an all zero covariance is unknown, not exact
+func poseAccuracy(covariance [36]float64) *movementsensor.Accuracy {
+	acc := movementsensor.UnimplementedOptionalAccuracies()
+	acc.AccuracyMap = map[string]float32{}
+	if covariance == [36]float64{} {
+		return acc
+	}
+	for i, name := range poseCovarianceNames {
+		variance := covariance[i*6+i]
+		if variance < 0 {
+			continue
+		}
+		acc.AccuracyMap["pose_"+name] = float32(math.Sqrt(variance))
+	}
+	return acc
+}
@@ -0,0 +1,29 @@
+package odometry
+
+import "fmt"
+
+type RosOdometryConfig struct {
+	NodeName      string  `json:"node_name"`
+	PrimaryUri    string  `json:"primary_uri"`
+	Topic         string  `json:"topic"`
+	OriginLat     float64 `json:"origin_lat"`
+	OriginLng     float64 `json:"origin_lng"`
+	OriginHeading float64 `json:"origin_heading_deg"` // compass heading of the odom x axis, defaults to 90 (east)
+}
+
+func (cfg *RosOdometryConfig) Validate(path string) ([]string, error) {
+	// NodeName will get default value if string is empty
+	if cfg.PrimaryUri == "" {
+		return nil, fmt.Errorf(`expected "PrimaryUri" attribute for sensor %q`, path)
+	}
+
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf(`expected "RosTopic" attribute for sensor %q`, path)
+	}
+
+	if cfg.OriginLat < -90 || cfg.OriginLat > 90 || cfg.OriginLng < -180 || cfg.OriginLng > 180 {
+		return nil, fmt.Errorf(`"origin_lat" and "origin_lng" must be a valid location for sensor %q`, path)
+	}
+
+	return nil, nil
+}
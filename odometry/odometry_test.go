@@ -0,0 +1,46 @@
+package odometry
+
+import (
+	"math"
+	"testing"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/test"
+)
+
+func TestPoseToGeo(t *testing.T) {
+	origin := geo.NewPoint(40.0, -74.0)
+
+	// default heading puts odom x east
+	p := poseToGeo(origin, 90, 100, 0)
+	test.That(t, origin.BearingTo(p), test.ShouldAlmostEqual, 90, 0.01)
+	test.That(t, origin.GreatCircleDistance(p)*1000, test.ShouldAlmostEqual, 100, 0.01)
+
+	p = poseToGeo(origin, 90, 0, 50)
+	test.That(t, origin.BearingTo(p), test.ShouldAlmostEqual, 0, 0.01)
+	test.That(t, origin.GreatCircleDistance(p)*1000, test.ShouldAlmostEqual, 50, 0.01)
+
+	p = poseToGeo(origin, 0, 0, 0)
+	test.That(t, p.Lat(), test.ShouldEqual, origin.Lat())
+	test.That(t, p.Lng(), test.ShouldEqual, origin.Lng())
+}
+
+func TestPoseAccuracy(t *testing.T) {
+	var covariance [36]float64
+	covariance[0] = 0.04
+	covariance[7] = 0.09
+	covariance[14] = -1
+	covariance[35] = 0.01
+
+	acc := poseAccuracy(covariance)
+	test.That(t, acc.AccuracyMap["pose_x"], test.ShouldAlmostEqual, 0.2, 1e-6)
+	test.That(t, acc.AccuracyMap["pose_y"], test.ShouldAlmostEqual, 0.3, 1e-6)
+	test.That(t, acc.AccuracyMap["pose_yaw"], test.ShouldAlmostEqual, 0.1, 1e-6)
+	_, ok := acc.AccuracyMap["pose_z"]
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, math.IsNaN(float64(acc.Hdop)), test.ShouldBeTrue)
+
+	// drivers that don't fill in the covariance publish all zeros
+	acc = poseAccuracy([36]float64{})
+	test.That(t, len(acc.AccuracyMap), test.ShouldEqual, 0)
+}